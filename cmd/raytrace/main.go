@@ -0,0 +1,340 @@
+// Command raytrace renders a scene file to an image, without needing a display.
+//
+// Usage:
+//
+//	raytrace -scene scene.json [-width w] [-height h] [-o out.png] [-workers n]
+//		[-samples n] [-max-samples n] [-noise-threshold t] [-heatmap heatmap.png]
+//		[-sampler regular|jittered|halton] [-seed n]
+//		[-filter box|tent|gaussian|mitchell|lanczos] [-filter-radius r]
+//		[-projection perspective|orthographic|fisheye|equirectangular]
+//		[-tile-size n] [-tile-order scanline|spiral|hilbert]
+//		[-tone-map clamp|reinhard|reinhard-extended|aces] [-white-point w] [-exposure stops]
+//		[-gamma g] [-depth 8|16] [-dither none|ordered|blue-noise]
+//		[-stereo side-by-side|top-bottom|anaglyph] [-interocular d] [-convergence d]
+//
+// If only one of -width and -height is given, the other is chosen to keep the scene camera's aspect ratio.
+// With -stereo, the scene is rendered once for each eye, and the two views are combined into one image;
+// -width and -height give the size of each eye's view.
+// The output format is chosen from the output file's extension (.png, .jpg or .jpeg); 16-bit output needs PNG.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/quevivasbien/go-raytracing/lib"
+)
+
+var samplers = map[string]Sampler{
+	"regular":  RegularSampler{},
+	"jittered": JitteredSampler{},
+	"halton":   HaltonSampler{},
+}
+
+var projections = map[string]Projection{
+	"perspective":     Perspective{},
+	"orthographic":    Orthographic{},
+	"fisheye":         Fisheye{},
+	"equirectangular": Equirectangular{},
+}
+
+// constructors for each filter, taking a radius, or 0 for the filter's default radius
+var filters = map[string]func(float64) Filter{
+	"box": func(r float64) Filter {
+		return BoxFilter{Radius: orDefault(r, 0.5)}
+	},
+	"tent": func(r float64) Filter {
+		return TentFilter{Radius: orDefault(r, 1)}
+	},
+	"gaussian": func(r float64) Filter {
+		r = orDefault(r, 1.5)
+		return GaussianFilter{Radius: r, Sigma: r / 3}
+	},
+	"mitchell": func(r float64) Filter {
+		return MakeMitchellFilter(orDefault(r, 2))
+	},
+	"lanczos": func(r float64) Filter {
+		return LanczosFilter{Radius: orDefault(r, 2)}
+	},
+}
+
+// constructors for each tone mapper, taking the white point used by reinhard-extended
+var toneMappers = map[string]func(float64) ToneMapper{
+	"clamp": func(float64) ToneMapper {
+		return ClampToneMapper{}
+	},
+	"reinhard": func(float64) ToneMapper {
+		return ReinhardToneMapper{}
+	},
+	"reinhard-extended": func(white float64) ToneMapper {
+		return ExtendedReinhardToneMapper{WhitePoint: white}
+	},
+	"aces": func(float64) ToneMapper {
+		return ACESToneMapper{}
+	},
+}
+
+// ways of combining the views from each eye into a single image
+var stereoLayouts = map[string]func(left, right image.Image) (*image.RGBA, error){
+	"side-by-side": SideBySide,
+	"top-bottom":   TopBottom,
+	"anaglyph":     Anaglyph,
+}
+
+func orDefault(x, fallback float64) float64 {
+	if x <= 0 {
+		return fallback
+	}
+	return x
+}
+
+func main() {
+	scenePath := flag.String("scene", "", "path to the scene file to render (required)")
+	width := flag.Int("width", 0, "width of the output image in pixels (default: from scene)")
+	height := flag.Int("height", 0, "height of the output image in pixels (default: from scene)")
+	output := flag.String("o", "out.png", "path of the output image; format is chosen by extension (.png, .jpg)")
+	workers := flag.Int("workers", 0, "number of parallel workers (default: number of CPU cores)")
+	samples := flag.Int("samples", 1, "number of rays traced through each pixel")
+	maxSamples := flag.Int("max-samples", 0, "if greater than -samples, keep sampling noisy pixels in rounds of -samples, up to this many")
+	noiseThreshold := flag.Float64("noise-threshold", DEFAULT_NOISE_THRESHOLD, "noise level below which adaptive sampling stops")
+	projection := flag.String("projection", "", "override the scene camera's projection: perspective, orthographic, fisheye or equirectangular")
+	heatmap := flag.String("heatmap", "", "if given, also write an image showing the number of samples taken for each pixel to this path")
+	sampler := flag.String("sampler", "regular", "where rays are traced within each pixel: regular, jittered or halton")
+	seed := flag.Uint64("seed", 0, "seed for random sampling; renders with the same seed are identical")
+	filter := flag.String("filter", "box", "how samples are weighted across nearby pixels: box, tent, gaussian, mitchell or lanczos")
+	filterRadius := flag.Float64("filter-radius", 0, "radius of the filter in pixels (default: depends on filter)")
+	tileSize := flag.Int("tile-size", DEFAULT_TILE_SIZE, "width and height of the tiles rendered by each worker")
+	tileOrder := flag.String("tile-order", "scanline", "order in which tiles are rendered: scanline, spiral or hilbert")
+	toneMap := flag.String("tone-map", "clamp", "how bright colors are mapped to the output: clamp, reinhard, reinhard-extended or aces")
+	whitePoint := flag.Float64("white-point", 4, "brightness that becomes pure white with -tone-map reinhard-extended")
+	exposure := flag.Float64("exposure", 0, "brightness adjustment in stops, applied before tone mapping; each stop doubles the brightness")
+	gamma := flag.Float64("gamma", 0, "encode the output with this gamma rather than sRGB; 1 writes linear values")
+	depth := flag.Int("depth", 8, "bits per channel of the output image: 8 or 16")
+	dither := flag.String("dither", "none", "noise added to break up banding: none, ordered or blue-noise")
+	stereo := flag.String("stereo", "", "render a stereo pair, combined as side-by-side, top-bottom or anaglyph")
+	interocular := flag.Float64("interocular", 0.065, "distance between the eyes for -stereo, in scene units")
+	convergence := flag.Float64("convergence", 0, "distance at which the eyes' views converge for -stereo (default: parallel views)")
+	flag.Parse()
+
+	order, err := ParseTileOrder(*tileOrder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raytrace: %v\n", err)
+		os.Exit(2)
+	}
+	pixelSampler, ok := samplers[*sampler]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "raytrace: unknown sampler %q; expected regular, jittered or halton\n", *sampler)
+		os.Exit(2)
+	}
+	makeFilter, ok := filters[*filter]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "raytrace: unknown filter %q; expected box, tent, gaussian, mitchell or lanczos\n", *filter)
+		os.Exit(2)
+	}
+	outputDither, err := ParseDither(*dither)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raytrace: %v\n", err)
+		os.Exit(2)
+	}
+	var transfer TransferFunction
+	if *gamma != 0 {
+		transfer = GammaTransfer{Gamma: *gamma}
+	}
+	makeToneMapper, ok := toneMappers[*toneMap]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "raytrace: unknown tone mapper %q; expected clamp, reinhard, reinhard-extended or aces\n", *toneMap)
+		os.Exit(2)
+	}
+	opts := RenderOptions{
+		Workers:        *workers,
+		Samples:        *samples,
+		MaxSamples:     *maxSamples,
+		NoiseThreshold: *noiseThreshold,
+		Sampler:        pixelSampler,
+		Seed:           *seed,
+		Filter:         makeFilter(*filterRadius),
+		TileSize:       *tileSize,
+		TileOrder:      order,
+		Display: Display{
+			ToneMapper: makeToneMapper(*whitePoint),
+			Exposure:   *exposure,
+			Transfer:   transfer,
+			Dither:     outputDither,
+		},
+	}
+	var cameraProjection Projection
+	if *projection != "" {
+		cameraProjection, ok = projections[*projection]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "raytrace: unknown projection %q; expected perspective, orthographic, fisheye or equirectangular\n", *projection)
+			os.Exit(2)
+		}
+	}
+	var stereoLayout func(left, right image.Image) (*image.RGBA, error)
+	if *stereo != "" {
+		stereoLayout, ok = stereoLayouts[*stereo]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "raytrace: unknown stereo layout %q; expected side-by-side, top-bottom or anaglyph\n", *stereo)
+			os.Exit(2)
+		}
+	}
+	rig := StereoRig{Interocular: *interocular, Convergence: *convergence}
+	if err := run(*scenePath, *width, *height, *output, *depth, *heatmap, cameraProjection, stereoLayout, rig, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "raytrace: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// if stereoLayout is non-nil, the scene is rendered with rig, which gets its camera from the scene
+func run(
+	scenePath string, width, height int, output string, depth int, heatmap string, projection Projection,
+	stereoLayout func(left, right image.Image) (*image.RGBA, error), rig StereoRig, opts RenderOptions,
+) error {
+	if scenePath == "" {
+		return fmt.Errorf("no scene given; use -scene to choose a scene file")
+	}
+	if width < 0 || height < 0 {
+		return fmt.Errorf("image size must be positive, got %dx%d", width, height)
+	}
+	if opts.Workers < 0 {
+		return fmt.Errorf("number of workers can't be negative, got %d", opts.Workers)
+	}
+	if opts.Samples < 1 {
+		return fmt.Errorf("number of samples must be at least 1, got %d", opts.Samples)
+	}
+	if opts.TileSize < 1 {
+		return fmt.Errorf("tile size must be at least 1, got %d", opts.TileSize)
+	}
+	if opts.NoiseThreshold <= 0 {
+		return fmt.Errorf("noise threshold must be positive, got %v", opts.NoiseThreshold)
+	}
+	if gamma, ok := opts.Transfer.(GammaTransfer); ok && gamma.Gamma <= 0 {
+		return fmt.Errorf("gamma must be positive, got %v", gamma.Gamma)
+	}
+	if depth != 8 && depth != 16 {
+		return fmt.Errorf("bit depth must be 8 or 16, got %d", depth)
+	}
+	if ext := strings.ToLower(filepath.Ext(output)); depth == 16 && ext != ".png" {
+		return fmt.Errorf("16-bit output needs a .png file, got %q", ext)
+	}
+	if stereoLayout != nil {
+		if heatmap != "" {
+			return fmt.Errorf("-heatmap can't be used with -stereo")
+		}
+		if depth != 8 {
+			return fmt.Errorf("-stereo only supports 8-bit output")
+		}
+		if rig.Interocular <= 0 {
+			return fmt.Errorf("interocular distance must be positive, got %v", rig.Interocular)
+		}
+		if rig.Convergence < 0 {
+			return fmt.Errorf("convergence distance can't be negative, got %v", rig.Convergence)
+		}
+	}
+	for _, path := range []string{output, heatmap} {
+		if _, err := encoderFor(path); path != "" && err != nil {
+			return err
+		}
+	}
+	scene, err := LoadScene(scenePath)
+	if err != nil {
+		return err
+	}
+
+	// fill in a missing dimension from the camera's aspect ratio
+	if width == 0 && height == 0 {
+		width, height = scene.Camera.Width, scene.Camera.Height
+	} else if width == 0 {
+		width = int(float64(height)*float64(scene.Camera.Width)/float64(scene.Camera.Height) + 0.5)
+	} else if height == 0 {
+		height = int(float64(width)*float64(scene.Camera.Height)/float64(scene.Camera.Width) + 0.5)
+	}
+	if width < 2 || height < 2 {
+		return fmt.Errorf("image must be at least 2x2 pixels, got %dx%d", width, height)
+	}
+	scene.Camera = scene.Camera.WithSize(width, height)
+	if projection != nil {
+		scene.Camera.Projection = projection
+	}
+
+	// on interrupt, stop rendering and save what has been rendered so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	timeStart := time.Now()
+	var result RenderResult
+	var renderErr error
+	if stereoLayout != nil {
+		rig.Camera = scene.Camera
+		var left, right *image.RGBA
+		left, right, renderErr = rig.RenderContext(ctx, scene, opts)
+		if right == nil {
+			// interrupted before the right eye was started
+			right = image.NewRGBA(left.Bounds())
+		}
+		if result.Image, err = stereoLayout(left, right); err != nil {
+			return err
+		}
+	} else {
+		result, renderErr = scene.RenderWithStats(ctx, opts)
+	}
+	if renderErr != nil {
+		fmt.Fprintf(os.Stderr, "Render interrupted after %v; saving partial image\n", time.Since(timeStart))
+	} else {
+		fmt.Fprintf(os.Stderr, "Rendered %dx%d in %v\n", width, height, time.Since(timeStart))
+	}
+
+	var img image.Image = result.Image
+	if depth == 16 {
+		img = result.Radiance.Image16(opts.Display)
+	}
+	if err := writeImage(output, img); err != nil {
+		return err
+	}
+	if heatmap != "" {
+		if err := writeImage(heatmap, result.SampleHeatmap()); err != nil {
+			return err
+		}
+	}
+	return renderErr
+}
+
+// write an image, in the format given by the path's extension
+func writeImage(path string, img image.Image) error {
+	encode, err := encoderFor(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// choose an image encoder based on the extension of the output path
+func encoderFor(path string) (func(*os.File, image.Image) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return func(f *os.File, img image.Image) error {
+			return png.Encode(f, img)
+		}, nil
+	case ".jpg", ".jpeg":
+		return func(f *os.File, img image.Image) error {
+			return jpeg.Encode(f, img, &jpeg.Options{Quality: 95})
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format %q; use .png or .jpg", filepath.Ext(path))
+}
@@ -1,6 +1,7 @@
 package gui
 
 import (
+	"context"
 	"image"
 	"math"
 
@@ -13,6 +14,9 @@ import (
 )
 
 const CONCURRENT bool = true
+
+// number of samples per pixel that renders are refined to
+const PROGRESSIVE_SAMPLES int = 16
 const MIN_WINDOW_WIDTH float32 = 480
 const MIN_WINDOW_HEIGHT float32 = 640
 
@@ -24,14 +28,36 @@ func emptyScene(width, height int) Scene {
 	}
 }
 
-func createImage(s Scene) *canvas.Image {
-	var img *image.RGBA
-	if CONCURRENT {
-		img = s.ConcurrentRender()
-	} else {
-		img = s.Render()
+// render the scene progressively, showing the image in the window after each pass
+// until the first pass is done, the window shows a progress bar instead
+func renderToWindow(ctx context.Context, w fyne.Window, s Scene) {
+	progress := widget.NewProgressBar()
+	w.SetContent(container.NewVBox(widget.NewLabel("Rendering..."), progress))
+	var shown *canvas.Image
+	opts := ProgressiveOptions{
+		RenderOptions: RenderOptions{
+			Sampler: HaltonSampler{},
+			Progress: func(p RenderProgress) {
+				if shown == nil {
+					progress.SetValue(p.Fraction())
+				}
+			},
+		},
+		TargetSamples: PROGRESSIVE_SAMPLES,
+		Snapshot: func(img *image.RGBA, samples int) {
+			if shown == nil {
+				shown = canvas.NewImageFromImage(img)
+				w.SetContent(shown)
+				return
+			}
+			shown.Image = img
+			shown.Refresh()
+		},
+	}
+	if !CONCURRENT {
+		opts.Workers = 1
 	}
-	return canvas.NewImageFromImage(img)
+	s.RenderProgressive(ctx, opts)
 }
 
 func resizeWindowToScene(w fyne.Window, s Scene) {
@@ -51,19 +77,22 @@ func Launch() {
 
 	widthEntry := createInput("1920", parseUint)
 	heightEntry := createInput("1080", parseUint)
+	fovEntry := createInput("90", parseAngle)
 
+	scene := emptyScene(1920, 1080)
 	form := widget.NewForm(
 		widget.NewFormItem("Width", widthEntry),
 		widget.NewFormItem("Height", heightEntry),
+		widget.NewFormItem("Field of view", fovEntry),
+		widget.NewFormItem("Lens", NewLensEntry(&scene.Camera)),
 	)
 
 	// create button for showing the rendering
 	var imageWindow fyne.Window
-	scene := emptyScene(1920, 1080)
 	scene.Lights = append(scene.Lights, MakeLight(Vector{0, -1, 1}, 1))
 	scene.Objects = append(scene.Objects, Object{
-		Shape:   Sphere{Center: Vector{0, 0, 5}, Radius: 1},
-		Surface: Surface{Ambient: 0, Diffuse: 0.5, Specular: 0, Color: Vector{1, 0, 0}},
+		Shape:    Sphere{Center: Vector{0, 0, 5}, Radius: 1},
+		Material: ClassicMaterial{Ambient: 0, Diffuse: 0.5, Specular: 0, Color: Vector{1, 0, 0}},
 	})
 	renderButton := widget.NewButton(
 		"Render",
@@ -76,13 +105,24 @@ func Launch() {
 			if err != nil {
 				return
 			}
+			fov, err := parseAngle(fovEntry.Text)
+			if err != nil {
+				return
+			}
+			camera, err := scene.Camera.WithSize(int(width), int(height)).WithFieldOfView(HorizontalFOV(fov * math.Pi / 180))
+			if err != nil {
+				return
+			}
+			scene.Camera = camera
 			imageWindow = createImageWindow(a, float32(width), float32(height))
-			scene.Camera = scene.Camera.Resized(int(width), int(height), math.Pi/4)
-			imageWindow.SetContent(widget.NewLabel("Rendering..."))
-			// render in a goroutine so the window can be shown with loading message while rendering
-			go func() {
-				imageWindow.SetContent(createImage(scene))
-			}()
+			// stop rendering if the window is closed before the render finishes
+			ctx, cancel := context.WithCancel(context.Background())
+			imageWindow.SetOnClosed(cancel)
+			// render in a goroutine so the window can be shown while rendering
+			go func(w fyne.Window, s Scene) {
+				defer cancel()
+				renderToWindow(ctx, w, s)
+			}(imageWindow, scene)
 			imageWindow.Show()
 		},
 	)
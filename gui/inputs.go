@@ -41,6 +41,31 @@ func parseUnitRange(s string) (float64, error) {
 	return f, nil
 }
 
+// extracts an angle of view in degrees, strictly between 0 and 180
+func parseAngle(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 || f >= 180 {
+		return 0, fmt.Errorf("Value should be an angle between 0 and 180 degrees, got %s", s)
+	}
+	return f, nil
+}
+
+func parseNonNegative(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("Value should be a non-negative number, got %s", s)
+	}
+	return f, nil
+}
+
+func parsePositive(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("Value should be a positive number, got %s", s)
+	}
+	return f, nil
+}
+
 func parseFloat(s string) (float64, error) {
 	f, err := strconv.ParseFloat(s, 64)
 	if err != nil {
@@ -87,21 +112,24 @@ func UpdateRectColor(rect *canvas.Rectangle, c *Vector) {
 	rect.Refresh()
 }
 
+// sliders give sRGB values, like other color pickers, which are converted to the linear values used for shading
 func NewColorEntry(v *Vector) *fyne.Container {
 	rEntry := NewUnitSlider()
 	gEntry := NewUnitSlider()
 	bEntry := NewUnitSlider()
 	swatch := NewColorSwatch(v)
+	srgb := SRGBTransfer{}
+	rEntry.Value, gEntry.Value, bEntry.Value = srgb.Encode(v.X), srgb.Encode(v.Y), srgb.Encode(v.Z)
 	rEntry.OnChanged = func(f float64) {
-		v.X = f
+		v.X = srgb.Decode(f)
 		UpdateRectColor(swatch, v)
 	}
 	gEntry.OnChanged = func(f float64) {
-		v.Y = f
+		v.Y = srgb.Decode(f)
 		UpdateRectColor(swatch, v)
 	}
 	bEntry.OnChanged = func(f float64) {
-		v.Z = f
+		v.Z = srgb.Decode(f)
 		UpdateRectColor(swatch, v)
 	}
 	return container.NewHBox(
@@ -112,10 +140,12 @@ func NewColorEntry(v *Vector) *fyne.Container {
 	)
 }
 
-func NewSurfaceEntry(s *Surface) *fyne.Container {
+func NewMaterialEntry(s *ClassicMaterial) *fyne.Container {
 	AmbientEntry := NewUnitSlider()
 	DiffuseEntry := NewUnitSlider()
 	SpecularEntry := NewUnitSlider()
+	HighlightEntry := NewUnitSlider()
+	ShininessEntry := createInput(fmt.Sprint(s.Shininess), parseFloat)
 	AmbientEntry.OnChanged = func(f float64) {
 		s.Ambient = f
 	}
@@ -125,13 +155,48 @@ func NewSurfaceEntry(s *Surface) *fyne.Container {
 	SpecularEntry.OnChanged = func(f float64) {
 		s.Specular = f
 	}
+	HighlightEntry.OnChanged = func(f float64) {
+		s.Highlight = f
+	}
+	ShininessEntry.OnChanged = func(str string) {
+		f, _ := parseFloat(str)
+		s.Shininess = f
+	}
 	colorEntry := NewColorEntry(&s.Color)
 	return container.NewVBox(
 		container.NewHBox(
 			NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Ambient"), AmbientEntry),
 			NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Diffuse"), DiffuseEntry),
-			NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Specular"), SpecularEntry),
+			NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Reflection"), SpecularEntry),
+		),
+		container.NewHBox(
+			NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Highlight"), HighlightEntry),
+			NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Shininess"), ShininessEntry),
 		),
 		colorEntry,
 	)
 }
+
+// entries for the camera's depth of field settings; an aperture of 0 keeps everything in focus
+func NewLensEntry(c *Camera) *fyne.Container {
+	ApertureEntry := createInput(fmt.Sprint(c.Aperture), parseNonNegative)
+	FocusEntry := createInput(fmt.Sprint(c.FocusDistance), parseNonNegative)
+	BladesEntry := createInput(fmt.Sprint(c.ApertureBlades), parseNonNegative)
+	ApertureEntry.OnChanged = func(str string) {
+		f, _ := parseNonNegative(str)
+		c.Aperture = f
+	}
+	FocusEntry.OnChanged = func(str string) {
+		f, _ := parseNonNegative(str)
+		c.FocusDistance = f
+	}
+	BladesEntry.OnChanged = func(str string) {
+		f, _ := parseNonNegative(str)
+		c.ApertureBlades = int(f)
+	}
+	return container.NewHBox(
+		NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Aperture"), ApertureEntry),
+		NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Focus distance"), FocusEntry),
+		NewStrictWidth(SLIDER_WIDTH, widget.NewLabel("Blades"), BladesEntry),
+	)
+}
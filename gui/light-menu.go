@@ -3,6 +3,7 @@ package gui
 import (
 	"fmt"
 	"image/color"
+	"math"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -12,32 +13,271 @@ import (
 	. "github.com/quevivasbien/go-raytracing/lib"
 )
 
-func addLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
-	coords := Vector{}
-	coordEntry := NewVectorEntry(&coords)
+// names shown for each falloff, in the order they're listed
+var falloffLabels = []struct {
+	label   string
+	falloff Falloff
+}{
+	{"None", NO_FALLOFF},
+	{"Linear", LINEAR_FALLOFF},
+	{"Inverse square", INVERSE_SQUARE_FALLOFF},
+}
+
+func falloffLabel(f Falloff) string {
+	for _, l := range falloffLabels {
+		if l.falloff == f {
+			return l.label
+		}
+	}
+	return f.String()
+}
+
+// form items for the brightness, color and falloff shared by most kinds of light
+func emissionFormItems(e *LightEmission) []*widget.FormItem {
 	intensityEntry := widget.NewSlider(0, 1)
-	intensityEntry.Value = 0.5
+	intensityEntry.Value = e.Intensity
 	intensityEntry.Step = 0.01
+	intensityEntry.OnChanged = func(f float64) {
+		e.Intensity = f
+	}
+	colorEntry := NewColorEntry(&e.Color)
+	var options []string
+	for _, l := range falloffLabels {
+		options = append(options, l.label)
+	}
+	falloffEntry := widget.NewSelect(options, func(str string) {
+		for _, l := range falloffLabels {
+			if l.label == str {
+				e.Falloff = l.falloff
+			}
+		}
+	})
+	falloffEntry.Selected = falloffLabel(e.Falloff)
+	radiusEntry := createInput("1", parsePositive)
+	radiusEntry.OnChanged = func(str string) {
+		e.FalloffRadius, _ = parsePositive(str)
+	}
+	return []*widget.FormItem{
+		widget.NewFormItem("Intensity", intensityEntry),
+		widget.NewFormItem("Color", colorEntry),
+		widget.NewFormItem("Falloff", falloffEntry),
+		widget.NewFormItem("Falloff radius", radiusEntry),
+	}
+}
+
+// a form with the given items, and a button that adds the light made by makeLight
+func lightForm(s *Scene, refreshCallback func(), makeLight func() Light, items ...*widget.FormItem) *fyne.Container {
 	submitButton := widget.NewButton("Add Light", func() {
-		s.Lights = append(s.Lights, MakeLight(coords, intensityEntry.Value))
+		s.Lights = append(s.Lights, makeLight())
 		refreshCallback()
 	})
 	return container.NewVBox(
-		widget.NewForm(
-			widget.NewFormItem("Position", coordEntry),
-			widget.NewFormItem("Intensity", intensityEntry),
-		),
+		widget.NewForm(items...),
 		WhiteSpace(0, 10),
 		submitButton,
 	)
 }
 
+func addPointLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	light := MakeLight(Vector{}, 0.5)
+	makeLight := func() Light {
+		light.Threshold = MakeLight(light.Position, light.Intensity).Threshold
+		return light
+	}
+	items := append([]*widget.FormItem{
+		widget.NewFormItem("Position", NewVectorEntry(&light.Position)),
+	}, emissionFormItems(&light.LightEmission)...)
+	return lightForm(s, refreshCallback, makeLight, items...)
+}
+
+func addSpotLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	direction := Vector{0, 0, 1}
+	light := MakeSpotLight(Vector{}, direction, 0, 0, 0.5)
+	innerEntry := createInput("20", parseAngle)
+	outerEntry := createInput("30", parseAngle)
+	makeLight := func() Light {
+		inner, _ := parseAngle(innerEntry.Text)
+		outer, _ := parseAngle(outerEntry.Text)
+		spot := MakeSpotLight(light.Position, direction, inner*math.Pi/180, outer*math.Pi/180, light.Intensity)
+		spot.LightEmission = light.LightEmission
+		return spot
+	}
+	items := append([]*widget.FormItem{
+		widget.NewFormItem("Position", NewVectorEntry(&light.Position)),
+		widget.NewFormItem("Direction", NewVectorEntry(&direction)),
+		widget.NewFormItem("Inner angle", innerEntry),
+		widget.NewFormItem("Outer angle", outerEntry),
+	}, emissionFormItems(&light.LightEmission)...)
+	return lightForm(s, refreshCallback, makeLight, items...)
+}
+
+func addDirectionalLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	light := MakeDirectionalLight(Vector{0, 1, 0}, 0.5)
+	intensityEntry := widget.NewSlider(0, 1)
+	intensityEntry.Value = light.Intensity
+	intensityEntry.Step = 0.01
+	intensityEntry.OnChanged = func(f float64) {
+		light.Intensity = f
+	}
+	makeLight := func() Light {
+		return light
+	}
+	return lightForm(s, refreshCallback, makeLight,
+		widget.NewFormItem("Direction", NewVectorEntry(&light.Direction)),
+		widget.NewFormItem("Intensity", intensityEntry),
+		widget.NewFormItem("Color", NewColorEntry(&light.Color)),
+	)
+}
+
+func addRectLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	// U x V is {0, 1, 0}, so the light faces the same way as the disk light's default normal
+	light := RectLight{U: Vector{0, 0, 1}, V: Vector{1, 0, 0}, LightEmission: MakeLightEmission(0.5)}
+	samplesEntry := createInput("4", parseUint)
+	makeLight := func() Light {
+		samples, _ := parseUint(samplesEntry.Text)
+		light.Samples = int(samples)
+		return light
+	}
+	items := append([]*widget.FormItem{
+		widget.NewFormItem("Center", NewVectorEntry(&light.Center)),
+		widget.NewFormItem("Side U", NewVectorEntry(&light.U)),
+		widget.NewFormItem("Side V", NewVectorEntry(&light.V)),
+		widget.NewFormItem("Samples", samplesEntry),
+	}, emissionFormItems(&light.LightEmission)...)
+	return lightForm(s, refreshCallback, makeLight, items...)
+}
+
+func addDiskLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	light := DiskLight{Normal: Vector{0, 1, 0}, LightEmission: MakeLightEmission(0.5)}
+	radiusEntry := createInput("1", parsePositive)
+	samplesEntry := createInput("4", parseUint)
+	makeLight := func() Light {
+		light.Radius, _ = parsePositive(radiusEntry.Text)
+		samples, _ := parseUint(samplesEntry.Text)
+		light.Samples = int(samples)
+		return light
+	}
+	items := append([]*widget.FormItem{
+		widget.NewFormItem("Center", NewVectorEntry(&light.Center)),
+		widget.NewFormItem("Normal vector", NewVectorEntry(&light.Normal)),
+		widget.NewFormItem("Radius", radiusEntry),
+		widget.NewFormItem("Samples", samplesEntry),
+	}, emissionFormItems(&light.LightEmission)...)
+	return lightForm(s, refreshCallback, makeLight, items...)
+}
+
+func addSphereLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	light := MakeSphereLight(Vector{}, 0, 0.5)
+	radiusEntry := createInput("1", parsePositive)
+	samplesEntry := createInput("4", parseUint)
+	makeLight := func() Light {
+		radius, _ := parsePositive(radiusEntry.Text)
+		sphere := MakeSphereLight(light.Center, radius, light.Intensity)
+		sphere.LightEmission = light.LightEmission
+		samples, _ := parseUint(samplesEntry.Text)
+		sphere.Samples = int(samples)
+		return sphere
+	}
+	items := append([]*widget.FormItem{
+		widget.NewFormItem("Center", NewVectorEntry(&light.Center)),
+		widget.NewFormItem("Radius", radiusEntry),
+		widget.NewFormItem("Samples", samplesEntry),
+	}, emissionFormItems(&light.LightEmission)...)
+	return lightForm(s, refreshCallback, makeLight, items...)
+}
+
+// menus for adding each kind of light, in the order they're listed
+var lightMenus = []struct {
+	label string
+	menu  func(*Scene, func()) *fyne.Container
+}{
+	{"Point", addPointLightMenu},
+	{"Spot", addSpotLightMenu},
+	{"Directional", addDirectionalLightMenu},
+	{"Rectangle", addRectLightMenu},
+	{"Disk", addDiskLightMenu},
+	{"Sphere", addSphereLightMenu},
+}
+
+func addLightMenu(s *Scene, refreshCallback func()) *fyne.Container {
+	addLightMenu := container.NewVBox(lightMenus[0].menu(s, refreshCallback))
+	var options []string
+	for _, m := range lightMenus {
+		options = append(options, m.label)
+	}
+	lightTypeEntry := widget.NewSelect(options, func(s string) {})
+	lightTypeEntry.Selected = lightMenus[0].label
+	menu := container.NewVBox(lightTypeEntry, addLightMenu)
+	lightTypeEntry.OnChanged = func(str string) {
+		for _, m := range lightMenus {
+			if m.label == str {
+				addLightMenu.Objects = []fyne.CanvasObject{m.menu(s, refreshCallback)}
+			}
+		}
+		addLightMenu.Refresh()
+	}
+	return menu
+}
+
 func showAddLightMenu(a *fyne.App, s *Scene, refreshCallback func()) {
 	lightWindow := (*a).NewWindow("Add Light")
 	lightWindow.SetContent(addLightMenu(s, refreshCallback))
 	lightWindow.Show()
 }
 
+func vectorLabel(name string, v Vector) *widget.Label {
+	return widget.NewLabel(fmt.Sprintf("%s: (%v, %v, %v)", name, v.X, v.Y, v.Z))
+}
+
+// labels describing the brightness, color and falloff of a light
+func emissionInfo(e LightEmission) []fyne.CanvasObject {
+	falloff := falloffLabel(e.Falloff)
+	if e.Falloff != NO_FALLOFF {
+		falloff = fmt.Sprintf("%s, radius %v", falloff, e.FalloffRadius)
+	}
+	return []fyne.CanvasObject{
+		widget.NewLabel(fmt.Sprintf("Intensity: %v", e.Intensity)),
+		container.NewVBox(layout.NewSpacer(), NewColorSwatch(&e.Color), layout.NewSpacer()),
+		widget.NewLabel(fmt.Sprintf("Falloff: %s", falloff)),
+	}
+}
+
+// labels describing a light, for the list of lights
+func lightInfo(l Light) []fyne.CanvasObject {
+	switch light := l.(type) {
+	case PointLight:
+		return append([]fyne.CanvasObject{widget.NewLabel("Point"), vectorLabel("Position", light.Position)}, emissionInfo(light.LightEmission)...)
+	case SpotLight:
+		return append([]fyne.CanvasObject{
+			widget.NewLabel("Spot"),
+			vectorLabel("Position", light.Position),
+			vectorLabel("Direction", light.Direction),
+		}, emissionInfo(light.LightEmission)...)
+	case DirectionalLight:
+		return []fyne.CanvasObject{
+			widget.NewLabel("Directional"),
+			vectorLabel("Direction", light.Direction),
+			widget.NewLabel(fmt.Sprintf("Intensity: %v", light.Intensity)),
+			container.NewVBox(layout.NewSpacer(), NewColorSwatch(&light.Color), layout.NewSpacer()),
+		}
+	case RectLight:
+		return append([]fyne.CanvasObject{widget.NewLabel("Rectangle"), vectorLabel("Center", light.Center)}, emissionInfo(light.LightEmission)...)
+	case DiskLight:
+		return append([]fyne.CanvasObject{
+			widget.NewLabel("Disk"),
+			vectorLabel("Center", light.Center),
+			widget.NewLabel(fmt.Sprintf("Radius: %v", light.Radius)),
+		}, emissionInfo(light.LightEmission)...)
+	case SphereLight:
+		return append([]fyne.CanvasObject{
+			widget.NewLabel("Sphere"),
+			vectorLabel("Center", light.Center),
+			widget.NewLabel(fmt.Sprintf("Radius: %v", light.Radius)),
+		}, emissionInfo(light.LightEmission)...)
+	}
+	return []fyne.CanvasObject{widget.NewLabel(fmt.Sprintf("Light: %v", l))}
+}
+
 func lightsContainer(a *fyne.App, s *Scene) *fyne.Container {
 	expander := canvas.NewRectangle(color.Transparent)
 	expander.SetMinSize(fyne.NewSize(0, 0))
@@ -56,16 +296,13 @@ func lightsContainer(a *fyne.App, s *Scene) *fyne.Container {
 			return container.NewHBox(widget.NewLabel(""))
 		},
 		func(i widget.ListItemID, obj fyne.CanvasObject) {
-			pos := s.Lights[i].Position
-			obj.(*fyne.Container).Objects = []fyne.CanvasObject{
-				widget.NewLabel(fmt.Sprintf("Position: (%v, %v, %v)", pos.X, pos.Y, pos.Z)),
-				widget.NewLabel(fmt.Sprintf("Intensity: %v", s.Lights[i].Intensity)),
+			obj.(*fyne.Container).Objects = append(lightInfo(s.Lights[i]),
 				layout.NewSpacer(),
 				widget.NewButton("Remove", func() {
 					s.Lights = append(s.Lights[:i], s.Lights[i+1:]...)
 					refreshObjectList()
 				}),
-			}
+			)
 		},
 	)
 	outerContainer = container.NewBorder(nil, nil, expander, nil, lightList)
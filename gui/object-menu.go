@@ -16,19 +16,19 @@ func addSphereMenu(s *Scene, refreshCallback func()) *fyne.Container {
 	coords := Vector{}
 	coordEntry := NewVectorEntry(&coords)
 	radiusEntry := createInput("1", parseFloat)
-	surface := Surface{}
-	surfaceEntry := NewSurfaceEntry(&surface)
+	material := ClassicMaterial{Shininess: 10}
+	materialEntry := NewMaterialEntry(&material)
 	submitButton := widget.NewButton("Add Sphere", func() {
 		radius, _ := parseFloat(radiusEntry.Text)
 		shape := Sphere{Center: coords, Radius: radius}
-		s.Objects = append(s.Objects, Object{Shape: shape, Surface: surface})
+		s.Objects = append(s.Objects, Object{Shape: shape, Material: material})
 		refreshCallback()
 	})
 	return container.NewVBox(
 		widget.NewForm(
 			widget.NewFormItem("Position", coordEntry),
 			widget.NewFormItem("Radius", radiusEntry),
-			widget.NewFormItem("Surface", surfaceEntry),
+			widget.NewFormItem("Material", materialEntry),
 		),
 		WhiteSpace(0, 10),
 		submitButton,
@@ -40,18 +40,18 @@ func addPlaneMenu(s *Scene, refreshCallback func()) *fyne.Container {
 	coordEntry := NewVectorEntry(&coords)
 	normalVec := Vector{0, 0, -1}
 	normalEntry := NewVectorEntry(&normalVec)
-	surface := Surface{}
-	surfaceEntry := NewSurfaceEntry(&surface)
+	material := ClassicMaterial{Shininess: 10}
+	materialEntry := NewMaterialEntry(&material)
 	submitButton := widget.NewButton("Add Plane", func() {
 		shape := Plane{Point: coords, Norm: normalVec.Unit()}
-		s.Objects = append(s.Objects, Object{Shape: shape, Surface: surface})
+		s.Objects = append(s.Objects, Object{Shape: shape, Material: material})
 		refreshCallback()
 	})
 	return container.NewVBox(
 		widget.NewForm(
 			widget.NewFormItem("Normal vector", normalEntry),
 			widget.NewFormItem("Point", coordEntry),
-			widget.NewFormItem("Surface", surfaceEntry),
+			widget.NewFormItem("Material", materialEntry),
 		),
 		WhiteSpace(0, 10),
 		submitButton,
@@ -117,19 +117,30 @@ func shapeInfo(s Shape) *fyne.Container {
 	)
 }
 
-func surfaceInfo(s Surface) *fyne.Container {
+func classicMaterialInfo(m ClassicMaterial) *fyne.Container {
 	return container.NewHBox(
-		container.NewHBox(widget.NewLabel("Color"), container.NewVBox(layout.NewSpacer(), NewColorSwatch(&s.Color), layout.NewSpacer())),
-		widget.NewLabel(fmt.Sprintf("Ambient: %.2f", s.Ambient)),
-		widget.NewLabel(fmt.Sprintf("Diffuse: %.2f", s.Diffuse)),
-		widget.NewLabel(fmt.Sprintf("Specular: %.2f", s.Specular)),
+		container.NewHBox(widget.NewLabel("Color"), container.NewVBox(layout.NewSpacer(), NewColorSwatch(&m.Color), layout.NewSpacer())),
+		widget.NewLabel(fmt.Sprintf("Ambient: %.2f", m.Ambient)),
+		widget.NewLabel(fmt.Sprintf("Diffuse: %.2f", m.Diffuse)),
+		widget.NewLabel(fmt.Sprintf("Reflection: %.2f", m.Specular)),
+		widget.NewLabel(fmt.Sprintf("Highlight: %.2f", m.Highlight)),
+	)
+}
+
+func materialInfo(m Material) *fyne.Container {
+	classic, ok := m.(ClassicMaterial)
+	if ok {
+		return classicMaterialInfo(classic)
+	}
+	return container.NewHBox(
+		widget.NewLabel(fmt.Sprintf("Material: %v", m)),
 	)
 }
 
 func objectInfo(o Object) *fyne.Container {
 	return container.NewVBox(
 		shapeInfo(o.Shape),
-		surfaceInfo(o.Surface),
+		materialInfo(o.Material),
 	)
 }
 
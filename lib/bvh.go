@@ -0,0 +1,337 @@
+package lib
+
+import "math"
+
+// max number of items stored in a single leaf of a bvh, unless their centers coincide
+const BVH_LEAF_SIZE int = 4
+
+// axis-aligned bounding box
+type AABB struct {
+	Min, Max Vector
+}
+
+func EmptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{Vector{inf, inf, inf}, Vector{-inf, -inf, -inf}}
+}
+
+func (b AABB) Union(c AABB) AABB {
+	return AABB{
+		Vector{math.Min(b.Min.X, c.Min.X), math.Min(b.Min.Y, c.Min.Y), math.Min(b.Min.Z, c.Min.Z)},
+		Vector{math.Max(b.Max.X, c.Max.X), math.Max(b.Max.Y, c.Max.Y), math.Max(b.Max.Z, c.Max.Z)},
+	}
+}
+
+func (b AABB) AddPoint(p Vector) AABB {
+	return b.Union(AABB{p, p})
+}
+
+func (b AABB) Center() Vector {
+	return b.Min.Add(b.Max).MulScalar(0.5)
+}
+
+// returns the distance along the ray at which it enters the box, or +Inf if it misses
+// invDir should hold the componentwise inverse of the ray direction
+func (b AABB) entry(r Ray, invDir Vector) float64 {
+	tNear, tFar := math.Inf(-1), math.Inf(1)
+	tNear, tFar = slab(b.Min.X, b.Max.X, r.Origin.X, invDir.X, tNear, tFar)
+	tNear, tFar = slab(b.Min.Y, b.Max.Y, r.Origin.Y, invDir.Y, tNear, tFar)
+	tNear, tFar = slab(b.Min.Z, b.Max.Z, r.Origin.Z, invDir.Z, tNear, tFar)
+	if tNear > tFar || tFar < 0 {
+		return math.Inf(1)
+	}
+	if tNear < 0 {
+		return 0
+	}
+	return tNear
+}
+
+// narrow the interval [tNear, tFar] to the part of the ray lying between min and max along one axis
+func slab(min, max, origin, inv, tNear, tFar float64) (float64, float64) {
+	t1 := (min - origin) * inv
+	t2 := (max - origin) * inv
+	if t1 > t2 {
+		t1, t2 = t2, t1
+	}
+	// comparisons are written so that NaNs (from 0 * Inf) are ignored
+	if t1 > tNear {
+		tNear = t1
+	}
+	if t2 < tFar {
+		tFar = t2
+	}
+	return tNear, tFar
+}
+
+func axisOf(v Vector, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+func inverse(v Vector) Vector {
+	return Vector{1 / v.X, 1 / v.Y, 1 / v.Z}
+}
+
+func (b AABB) surfaceArea() float64 {
+	d := b.Max.Sub(b.Min)
+	if d.X < 0 || d.Y < 0 || d.Z < 0 {
+		return 0
+	}
+	return 2 * (d.X*d.Y + d.Y*d.Z + d.Z*d.X)
+}
+
+// implemented by shapes with finite extent, which allows them to be placed in a bvh
+type Bounded interface {
+	Bounds() AABB
+}
+
+// implemented by shapes that may or may not have finite extent, depending on what they're made of
+type maybeBounded interface {
+	bounds() (AABB, bool)
+}
+
+// get the bounds of a shape, or false if it has no finite extent
+func boundsOf(s Shape) (AABB, bool) {
+	switch shape := s.(type) {
+	case Bounded:
+		return shape.Bounds(), true
+	case maybeBounded:
+		return shape.bounds()
+	}
+	return AABB{}, false
+}
+
+type bvhNode struct {
+	bounds AABB
+	// for leaves, the range of items covered is items[offset:offset+count]
+	// for interior nodes, count is 0, the first child is the next node, and offset is the index of the second child
+	offset, count int
+}
+
+// bounding volume hierarchy over a list of items, identified by their indices
+type bvh struct {
+	nodes []bvhNode
+	items []int
+}
+
+// number of buckets used when evaluating candidate splits
+const SAH_BINS int = 16
+
+// cost of traversing an interior node, relative to the cost of intersecting a single item
+const SAH_TRAVERSAL_COST float64 = 0.125
+
+// build a bvh over items with the given bounds, splitting nodes according to the surface area heuristic
+func buildBVH(bounds []AABB) bvh {
+	t := bvh{items: make([]int, len(bounds))}
+	centers := make([]Vector, len(bounds))
+	for i := range t.items {
+		t.items[i] = i
+		centers[i] = bounds[i].Center()
+	}
+	if len(bounds) > 0 {
+		t.build(bounds, centers, 0, len(bounds))
+	}
+	return t
+}
+
+type sahBin struct {
+	bounds AABB
+	count  int
+}
+
+func (t *bvh) build(bounds []AABB, centers []Vector, start, end int) {
+	box := EmptyAABB()
+	centroids := EmptyAABB()
+	for _, item := range t.items[start:end] {
+		box = box.Union(bounds[item])
+		centroids = centroids.AddPoint(centers[item])
+	}
+	index := len(t.nodes)
+	t.nodes = append(t.nodes, bvhNode{bounds: box, offset: start, count: end - start})
+	count := end - start
+	if count == 1 {
+		return
+	}
+
+	// find the cheapest split plane among evenly spaced candidates on each axis
+	bestAxis, bestSplit, bestCost := -1, 0, math.Inf(1)
+	area := box.surfaceArea()
+	for axis := 0; axis < 3; axis++ {
+		low, high := axisOf(centroids.Min, axis), axisOf(centroids.Max, axis)
+		if high <= low {
+			continue
+		}
+		var bins [SAH_BINS]sahBin
+		for i := range bins {
+			bins[i].bounds = EmptyAABB()
+		}
+		for _, item := range t.items[start:end] {
+			b := sahBinIndex(axisOf(centers[item], axis), low, high)
+			bins[b].bounds = bins[b].bounds.Union(bounds[item])
+			bins[b].count++
+		}
+		// sweep from the right to get the cost of everything above each split
+		var rightArea [SAH_BINS]float64
+		var rightCount [SAH_BINS]int
+		acc, n := EmptyAABB(), 0
+		for i := SAH_BINS - 1; i > 0; i-- {
+			acc = acc.Union(bins[i].bounds)
+			n += bins[i].count
+			rightArea[i], rightCount[i] = acc.surfaceArea(), n
+		}
+		acc, n = EmptyAABB(), 0
+		for split := 1; split < SAH_BINS; split++ {
+			acc = acc.Union(bins[split-1].bounds)
+			n += bins[split-1].count
+			if n == 0 || rightCount[split] == 0 {
+				continue
+			}
+			cost := SAH_TRAVERSAL_COST + (acc.surfaceArea()*float64(n)+rightArea[split]*float64(rightCount[split]))/area
+			if cost < bestCost {
+				bestAxis, bestSplit, bestCost = axis, split, cost
+			}
+		}
+	}
+	if bestAxis < 0 {
+		// all centroids coincide, so no split can separate them
+		return
+	}
+	if count <= BVH_LEAF_SIZE && bestCost >= float64(count) {
+		// intersecting everything directly is cheaper than splitting further
+		return
+	}
+
+	low, high := axisOf(centroids.Min, bestAxis), axisOf(centroids.Max, bestAxis)
+	mid := start
+	for i := start; i < end; i++ {
+		if sahBinIndex(axisOf(centers[t.items[i]], bestAxis), low, high) < bestSplit {
+			t.items[i], t.items[mid] = t.items[mid], t.items[i]
+			mid++
+		}
+	}
+	t.build(bounds, centers, start, mid)
+	second := len(t.nodes)
+	t.build(bounds, centers, mid, end)
+	t.nodes[index].offset = second
+	t.nodes[index].count = 0
+}
+
+func sahBinIndex(x, low, high float64) int {
+	b := int(float64(SAH_BINS) * (x - low) / (high - low))
+	if b >= SAH_BINS {
+		b = SAH_BINS - 1
+	}
+	if b < 0 {
+		b = 0
+	}
+	return b
+}
+
+// walk the tree along the ray, calling visit for each item in the leaves that the ray passes through
+// visit should return the distance beyond which nothing more needs to be visited;
+// nodes are visited front to back, and skipped if the ray enters them beyond that distance
+func (t bvh) walk(r Ray, visit func(int) float64) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	maxDist := math.Inf(1)
+	invDir := inverse(r.Direction.Vector)
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		index := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := t.nodes[index]
+		if dist := node.bounds.entry(r, invDir); math.IsInf(dist, 1) || dist > maxDist {
+			continue
+		}
+		if node.count > 0 {
+			for _, item := range t.items[node.offset : node.offset+node.count] {
+				maxDist = visit(item)
+			}
+			continue
+		}
+		first, second := index+1, node.offset
+		// visit the nearer child first, so that farther nodes are more likely to be culled
+		if t.nodes[second].bounds.entry(r, invDir) < t.nodes[first].bounds.entry(r, invDir) {
+			first, second = second, first
+		}
+		stack = append(stack, second, first)
+	}
+}
+
+// find the item closest along the ray
+// hit should return the distance at which the ray intersects the given item, or +Inf if it misses
+// returns the index of the closest item (-1 if none is hit) and its distance
+func (t bvh) closest(r Ray, hit func(int) float64) (int, float64) {
+	best, bestDist := -1, math.Inf(1)
+	t.walk(r, func(item int) float64 {
+		if dist := hit(item); dist < bestDist {
+			best, bestDist = item, dist
+		}
+		return bestDist
+	})
+	return best, bestDist
+}
+
+// check whether the ray hits any item before reaching maxDist, stopping at the first one found
+// hit should report whether the ray intersects the given item closer than maxDist
+func (t bvh) any(r Ray, maxDist float64, hit func(int) bool) bool {
+	if len(t.nodes) == 0 {
+		return false
+	}
+	invDir := inverse(r.Direction.Vector)
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		index := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := t.nodes[index]
+		if dist := node.bounds.entry(r, invDir); math.IsInf(dist, 1) || dist > maxDist {
+			continue
+		}
+		if node.count > 0 {
+			for _, item := range t.items[node.offset : node.offset+node.count] {
+				if hit(item) {
+					return true
+				}
+			}
+			continue
+		}
+		stack = append(stack, node.offset, index+1)
+	}
+	return false
+}
+
+// relative amount by which object bounds are padded, so that floating point error
+// can't make the bvh cull an intersection that a brute-force search would find
+const BVH_PADDING float64 = 1e-9
+
+// acceleration structure for finding intersections with a scene's objects
+type sceneAccel struct {
+	tree bvh
+	// index into the scene's objects for each item in the tree
+	bounded []int
+	// objects without bounds (e.g. planes), which are always tested directly
+	unbounded []int
+}
+
+func buildSceneAccel(objects []Object) *sceneAccel {
+	accel := sceneAccel{}
+	var bounds []AABB
+	for i, object := range objects {
+		b, ok := boundsOf(object.Shape)
+		if !ok {
+			accel.unbounded = append(accel.unbounded, i)
+			continue
+		}
+		pad := b.Max.Sub(b.Min).AddScalar(1).MulScalar(BVH_PADDING)
+		accel.bounded = append(accel.bounded, i)
+		bounds = append(bounds, AABB{b.Min.Sub(pad), b.Max.Add(pad)})
+	}
+	accel.tree = buildBVH(bounds)
+	return &accel
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"math"
+	"testing"
+)
+
+// a scene with many bounded objects and an unbounded plane, placed randomly in front of the default camera
+func crowdedScene(n int) Scene {
+	s := Scene{Camera: DefaultCamera(64, 48)}
+	rng := MakeRNG(3)
+	point := func() Vector {
+		return Vector{rng.Float64()*8 - 4, rng.Float64()*6 - 3, rng.Float64()*10 + 3}
+	}
+	material := ClassicMaterial{Ambient: 0.1, Diffuse: 0.6, Specular: 0.3, Color: Vector{0.8, 0.6, 0.4}, Highlight: 0.5, Shininess: 20}
+	for i := 0; i < n; i++ {
+		var shape Shape
+		if i%2 == 0 {
+			shape = Sphere{Center: point(), Radius: rng.Float64() * 0.4}
+		} else {
+			a := point()
+			shape = Triangle{Vertices: [3]Vector{a, a.Add(point().MulScalar(0.1)), a.Add(point().MulScalar(0.1))}}
+		}
+		s.Objects = append(s.Objects, Object{Shape: shape, Material: material})
+	}
+	s.Objects = append(s.Objects, Object{Shape: Plane{Point: Vector{0, 3, 0}, Norm: Vector{0, -1, 0}.Unit()}, Material: material})
+	s.Lights = []Light{MakeLight(Vector{-2, -4, 2}, 0.8)}
+	return s
+}
+
+// check that rendering s with its bvh gives exactly the same image as testing every object
+func checkMatchesBruteForce(t *testing.T, s Scene) {
+	t.Helper()
+	img := s.RenderWithOptions(RenderOptions{})
+	for y := 0; y < s.Camera.Height; y++ {
+		for x := 0; x < s.Camera.Width; x++ {
+			// s.accel is nil, so RenderPixel tests every object
+			if want, got := s.RenderPixel(x, y), img.RGBAAt(x, y); want != got {
+				t.Errorf("pixel (%d, %d) is %v with the bvh, but %v without", x, y, got, want)
+			}
+		}
+	}
+}
+
+// the bvh must find exactly the same intersections as testing every object, including ties between overlapping objects
+func TestSceneAccelMatchesBruteForce(t *testing.T) {
+	checkMatchesBruteForce(t, crowdedScene(300))
+}
+
+// shadow rays toward a directional light have no maximum distance, but must still skip boxes they miss
+func TestSceneAccelDirectionalLight(t *testing.T) {
+	s := crowdedScene(300)
+	s.Lights = []Light{MakeDirectionalLight(Vector{1, 2, 0.5}, 0.8)}
+	checkMatchesBruteForce(t, s)
+
+	accel := buildSceneAccel(s.Objects)
+	// from beside the crowd, pointing away from it
+	r := Ray{Origin: Vector{10, 0, 8}, Direction: Vector{1, -1, 0}.Unit()}
+	tested := 0
+	accel.tree.any(r, math.Inf(1), func(int) bool {
+		tested++
+		return false
+	})
+	if tested > 0 {
+		t.Errorf("ray missing every object was tested against %d of them", tested)
+	}
+}
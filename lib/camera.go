@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+)
+
+// describes how much of the scene a camera sees
+type FieldOfView interface {
+	// get half the width of the image plane at distance 1 from the camera, for an image of the given size
+	halfWidth(width, height int) (float64, error)
+}
+
+// full horizontal angle of view, in radians
+type HorizontalFOV float64
+
+func (fov HorizontalFOV) halfWidth(width, height int) (float64, error) {
+	if err := checkAngle(float64(fov)); err != nil {
+		return 0, err
+	}
+	return math.Tan(float64(fov) / 2), nil
+}
+
+// full vertical angle of view, in radians
+type VerticalFOV float64
+
+func (fov VerticalFOV) halfWidth(width, height int) (float64, error) {
+	if err := checkAngle(float64(fov)); err != nil {
+		return 0, err
+	}
+	return math.Tan(float64(fov)/2) * float64(width) / float64(height), nil
+}
+
+func checkAngle(angle float64) error {
+	if !(angle > 0 && angle < math.Pi) {
+		return fmt.Errorf("field of view must be between 0 and pi radians, got %v", angle)
+	}
+	return nil
+}
+
+// angle of view given by a lens's focal length and the width of the sensor behind it, in the same units
+// e.g. a 50mm lens on a 36mm wide full-frame sensor
+type FocalLength struct {
+	Focal, SensorWidth float64
+}
+
+func (f FocalLength) halfWidth(width, height int) (float64, error) {
+	if !(f.Focal > 0) || math.IsInf(f.Focal, 1) {
+		return 0, fmt.Errorf("focal length must be positive, got %v", f.Focal)
+	}
+	if !(f.SensorWidth > 0) || math.IsInf(f.SensorWidth, 1) {
+		return 0, fmt.Errorf("sensor width must be positive, got %v", f.SensorWidth)
+	}
+	return f.SensorWidth / (2 * f.Focal), nil
+}
+
+// make a camera at eye, looking toward target, oriented so that worldUp points toward the top of the image
+// worldUp doesn't need to be perpendicular to the view direction, but can't be parallel to it
+func MakeLookAtCamera(width, height int, eye, target, worldUp Vector, fov FieldOfView) (Camera, error) {
+	if width < 2 || height < 2 {
+		return Camera{}, fmt.Errorf("image must be at least 2x2 pixels, got %dx%d", width, height)
+	}
+	if !finite(eye) || !finite(target) || !finite(worldUp) {
+		return Camera{}, fmt.Errorf("camera position, target and up direction must be finite")
+	}
+	forward := target.Sub(eye)
+	if forward.Dot(forward) == 0 {
+		return Camera{}, fmt.Errorf("camera target must differ from its position %v", eye)
+	}
+	if worldUp.Dot(worldUp) == 0 {
+		return Camera{}, fmt.Errorf("up direction must be non-zero")
+	}
+	right := forward.Cross(worldUp)
+	// compare the sine of the angle between forward and worldUp against a small tolerance
+	if right.Dot(right) <= 1e-18*forward.Dot(forward)*worldUp.Dot(worldUp) {
+		return Camera{}, fmt.Errorf("up direction %v is parallel to the view direction %v", worldUp, forward)
+	}
+	if fov == nil {
+		return Camera{}, fmt.Errorf("no field of view given")
+	}
+	halfWidth, err := fov.halfWidth(width, height)
+	if err != nil {
+		return Camera{}, err
+	}
+	lookAt := forward.Unit()
+	r := right.Unit()
+	// pixel rows run from the top of the image down, so the camera's Up points toward the bottom of the image
+	down := lookAt.Cross(r.Vector).Unit()
+	return MakeCamera(width, height, eye, lookAt, down, r, math.Atan(halfWidth)), nil
+}
+
+// same camera with a different field of view
+func (c Camera) WithFieldOfView(fov FieldOfView) (Camera, error) {
+	if fov == nil {
+		return Camera{}, fmt.Errorf("no field of view given")
+	}
+	halfWidth, err := fov.halfWidth(c.Width, c.Height)
+	if err != nil {
+		return Camera{}, err
+	}
+	return c.withHalfWidth(halfWidth), nil
+}
+
+func (c Camera) withHalfWidth(halfWidth float64) Camera {
+	c.HalfWidth = halfWidth
+	return c.WithSize(c.Width, c.Height)
+}
+
+// full horizontal angle of view, in radians
+func (c Camera) HorizontalFieldOfView() float64 {
+	return 2 * math.Atan(c.HalfWidth)
+}
+
+// full vertical angle of view, in radians
+func (c Camera) VerticalFieldOfView() float64 {
+	return 2 * math.Atan(c.HalfHeight)
+}
+
+func finite(v Vector) bool {
+	for _, x := range []float64{v.X, v.Y, v.Z} {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+package lib
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sync"
+)
+
+// converts between the linear values used for shading and the values stored in images
+type TransferFunction interface {
+	// map a linear value in [0, 1] to an encoded value in [0, 1]
+	Encode(x float64) float64
+	// inverse of Encode
+	Decode(x float64) float64
+}
+
+// the transfer function used if none is given
+func DefaultTransfer() TransferFunction {
+	return SRGBTransfer{}
+}
+
+// the sRGB transfer function, expected by most image viewers and file formats
+type SRGBTransfer struct{}
+
+func (SRGBTransfer) Encode(x float64) float64 {
+	if x <= 0.0031308 {
+		return 12.92 * x
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+func (SRGBTransfer) Decode(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+// a pure power law; encoded values are linear values raised to 1 / Gamma, which should be positive
+// Gamma 1 stores linear values unchanged, and 2.2 approximates sRGB
+type GammaTransfer struct {
+	Gamma float64
+}
+
+func (t GammaTransfer) Encode(x float64) float64 {
+	return math.Pow(x, 1/t.Gamma)
+}
+
+func (t GammaTransfer) Decode(x float64) float64 {
+	return math.Pow(x, t.Gamma)
+}
+
+// apply a transfer function's encoding to each channel of a color
+func encodeColor(c Vector, t TransferFunction) Vector {
+	return Vector{t.Encode(c.X), t.Encode(c.Y), t.Encode(c.Z)}
+}
+
+// convert a color given in sRGB, e.g. picked in a color chooser, to linear values for shading
+func DecodeSRGB(c Vector) Vector {
+	t := SRGBTransfer{}
+	return Vector{t.Decode(c.X), t.Decode(c.Y), t.Decode(c.Z)}
+}
+
+// convert a linear color to sRGB, e.g. for showing it in a color swatch
+func EncodeSRGB(c Vector) Vector {
+	return encodeColor(c, SRGBTransfer{})
+}
+
+// noise added to pixel values before they're quantized, so that smooth gradients don't show bands
+type Dither int
+
+const (
+	// values are rounded down, which can show bands in smooth gradients
+	NO_DITHER Dither = iota
+	// a repeating 8x8 Bayer matrix, which gives a fine, regular cross-hatch pattern
+	ORDERED_DITHER
+	// a repeating tile of blue noise, which gives fine, irregular grain with no visible pattern
+	BLUE_NOISE_DITHER
+)
+
+var ditherNames = map[Dither]string{
+	NO_DITHER:         "none",
+	ORDERED_DITHER:    "ordered",
+	BLUE_NOISE_DITHER: "blue-noise",
+}
+
+func (d Dither) String() string {
+	if name, ok := ditherNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("Dither(%d)", int(d))
+}
+
+// get the dither with the given name, as returned by Dither.String
+func ParseDither(name string) (Dither, error) {
+	for dither, n := range ditherNames {
+		if n == name {
+			return dither, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown dither %q; expected none, ordered or blue-noise", name)
+}
+
+// offset in [0, 1) added to a pixel's values, in units of the quantization step, before rounding down
+func (d Dither) threshold(x, y int) float64 {
+	switch d {
+	case ORDERED_DITHER:
+		return bayerMatrix[y&7][x&7]
+	case BLUE_NOISE_DITHER:
+		blueNoiseOnce.Do(makeBlueNoise)
+		return blueNoise[y&(BLUE_NOISE_SIZE-1)][x&(BLUE_NOISE_SIZE-1)]
+	}
+	return 0
+}
+
+// 8x8 Bayer matrix, with thresholds spread evenly in [0, 1)
+var bayerMatrix = func() (m [8][8]float64) {
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			// interleave the bits of x ^ y and y, with the lowest bits of the coordinates giving the highest bits of the rank
+			rank, a, b := 0, x^y, y
+			for bit := 0; bit < 3; bit++ {
+				rank = rank<<2 | (a>>bit&1)<<1 | b>>bit&1
+			}
+			m[y][x] = (float64(rank) + 0.5) / 64
+		}
+	}
+	return
+}()
+
+// width and height of the blue noise tile; must be a power of 2
+const BLUE_NOISE_SIZE int = 64
+
+var blueNoise [BLUE_NOISE_SIZE][BLUE_NOISE_SIZE]float64
+var blueNoiseOnce sync.Once
+
+// generate a tile of blue noise with the void-and-cluster method, which ranks the pixels so that
+// every prefix of the ranking is spread out as evenly as possible
+func makeBlueNoise() {
+	const n = BLUE_NOISE_SIZE
+	const sigma = 1.5
+	// energy of each pixel is the sum of a gaussian of its toroidal distance to each chosen pixel
+	var kernel [n][n]float64
+	for dy := 0; dy < n; dy++ {
+		for dx := 0; dx < n; dx++ {
+			wx, wy := math.Min(float64(dx), float64(n-dx)), math.Min(float64(dy), float64(n-dy))
+			kernel[dy][dx] = math.Exp(-(wx*wx + wy*wy) / (2 * sigma * sigma))
+		}
+	}
+	var energy [n][n]float64
+	var chosen [n][n]bool
+	update := func(x, y int, sign float64) {
+		for py := 0; py < n; py++ {
+			for px := 0; px < n; px++ {
+				energy[py][px] += sign * kernel[(py-y+n)%n][(px-x+n)%n]
+			}
+		}
+	}
+	// find the chosen pixel with the highest energy (the tightest cluster) or the unchosen one with the lowest (the largest void)
+	extreme := func(want bool) (int, int) {
+		bestX, bestY, best := -1, -1, 0.
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				if chosen[y][x] != want {
+					continue
+				}
+				e := energy[y][x]
+				if want {
+					e = -e
+				}
+				if bestX < 0 || e < best {
+					bestX, bestY, best = x, y, e
+				}
+			}
+		}
+		return bestX, bestY
+	}
+
+	// start from a sparse random pattern, then even it out by moving pixels from clusters to voids
+	rng := MakeRNG(1)
+	initial := n * n / 10
+	for i := 0; i < initial; {
+		x, y := int(rng.Uint64()%uint64(n)), int(rng.Uint64()%uint64(n))
+		if !chosen[y][x] {
+			chosen[y][x] = true
+			update(x, y, 1)
+			i++
+		}
+	}
+	for {
+		cx, cy := extreme(true)
+		chosen[cy][cx] = false
+		update(cx, cy, -1)
+		vx, vy := extreme(false)
+		chosen[vy][vx] = true
+		update(vx, vy, 1)
+		if vx == cx && vy == cy {
+			break
+		}
+	}
+	prototype, prototypeEnergy := chosen, energy
+
+	var rank [n][n]int
+	// rank the initial pixels by removing the tightest cluster first, so that it gets the highest rank among them
+	for r := initial - 1; r >= 0; r-- {
+		x, y := extreme(true)
+		chosen[y][x] = false
+		update(x, y, -1)
+		rank[y][x] = r
+	}
+	// then rank the rest by filling the largest void next
+	chosen, energy = prototype, prototypeEnergy
+	for r := initial; r < n*n; r++ {
+		x, y := extreme(false)
+		chosen[y][x] = true
+		update(x, y, 1)
+		rank[y][x] = r
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			blueNoise[y][x] = (float64(rank[y][x]) + 0.5) / float64(n*n)
+		}
+	}
+}
+
+// how the linear radiance of each pixel is turned into the values stored in an image
+type Display struct {
+	// maps radiance to displayable colors; if nil, uses DefaultToneMapper(), which clips bright colors
+	ToneMapper ToneMapper
+	// brightness adjustment applied before tone mapping, in stops; each stop doubles the radiance
+	Exposure float64
+	// encodes the tone mapped linear colors for storage; if nil, uses DefaultTransfer(), which gives sRGB
+	Transfer TransferFunction
+	// noise added before quantizing, to break up banding
+	Dither Dither
+}
+
+// fill in default values for unset fields
+func (d Display) withDefaults() Display {
+	if d.ToneMapper == nil {
+		d.ToneMapper = DefaultToneMapper()
+	}
+	if d.Transfer == nil {
+		d.Transfer = DefaultTransfer()
+	}
+	return d
+}
+
+// tone map and encode a linear color, giving the values in [0, 1] stored in an image
+// d should have defaults filled in
+func (d *Display) encode(c Vector) Vector {
+	return encodeColor(toneMap(c, d.ToneMapper, d.Exposure), d.Transfer)
+}
+
+// quantize a value in [0, 1] to an integer in [0, max], adding a dither threshold in [0, 1) first
+func quantize(v, max, threshold float64) float64 {
+	return math.Min(math.Floor(v*max+threshold), max)
+}
+
+// convert a linear color into an 8-bit pixel at (x, y)
+// d should have defaults filled in
+func (d *Display) rgba(c Vector, x, y int) color.RGBA {
+	e, t := d.encode(c), d.Dither.threshold(x, y)
+	return color.RGBA{
+		uint8(quantize(e.X, math.MaxUint8, t)),
+		uint8(quantize(e.Y, math.MaxUint8, t)),
+		uint8(quantize(e.Z, math.MaxUint8, t)),
+		math.MaxUint8,
+	}
+}
+
+// convert a linear color into a 16-bit pixel at (x, y)
+// d should have defaults filled in
+func (d *Display) rgba64(c Vector, x, y int) color.RGBA64 {
+	e, t := d.encode(c), d.Dither.threshold(x, y)
+	return color.RGBA64{
+		uint16(quantize(e.X, math.MaxUint16, t)),
+		uint16(quantize(e.Y, math.MaxUint16, t)),
+		uint16(quantize(e.Z, math.MaxUint16, t)),
+		math.MaxUint16,
+	}
+}
@@ -1,24 +1,300 @@
 package lib
 
-import "image/color"
+import (
+	"context"
+	"image"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
 
-type Job struct {
-	Scene      *Scene
-	Start, End int
+// default noise level below which adaptive sampling stops taking samples for a pixel
+const DEFAULT_NOISE_THRESHOLD float64 = 0.01
+
+// options controlling how a scene is rendered
+type RenderOptions struct {
+	// number of tiles rendered in parallel; if 0, uses the number of CPU cores
+	Workers int
+	// number of rays traced through each pixel, whose colors are averaged; if 0, uses 1
+	Samples int
+	// if greater than Samples, enables adaptive sampling: after the first Samples samples,
+	// pixels whose estimated noise is above NoiseThreshold get further rounds of Samples samples, up to MaxSamples in total
+	// this works best with a random or low-discrepancy sampler
+	MaxSamples int
+	// max standard error of the mean displayed brightness (in [0, 1]) of an adaptively sampled pixel; if 0, uses DEFAULT_NOISE_THRESHOLD
+	NoiseThreshold float64
+	// chooses where in each pixel rays are traced through; if nil, uses RegularSampler
+	Sampler Sampler
+	// seed for the random numbers used while rendering; renders with the same seed and options are identical
+	Seed uint64
+	// width and height of the tiles that the image is split into; if 0, uses DEFAULT_TILE_SIZE
+	TileSize int
+	// order in which tiles are handed out to workers
+	TileOrder TileOrder
+	// weights how samples contribute to the pixels around them; if nil, uses DefaultFilter()
+	Filter Filter
+	// how the rendered radiance is turned into the pixels of the image
+	Display
+	// if not nil, called each time a tile of the image has been rendered
+	// calls are made one at a time, from the goroutine that started the render
+	Progress func(RenderProgress)
+}
+
+// how much of an image has been rendered so far
+type RenderProgress struct {
+	// number of tiles of the image that have been rendered, out of Total
+	Completed, Total int
+	// the most recently rendered tile
+	Tile image.Rectangle
+}
+
+func (p RenderProgress) Fraction() float64 {
+	if p.Total == 0 {
+		return 1
+	}
+	return float64(p.Completed) / float64(p.Total)
 }
 
-type JobResult struct {
-	Pixels []*color.RGBA
-	Start  int
+// fill in default values for unset options
+func (opts RenderOptions) withDefaults() RenderOptions {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = 1
+	}
+	if opts.NoiseThreshold <= 0 {
+		opts.NoiseThreshold = DEFAULT_NOISE_THRESHOLD
+	}
+	if opts.Sampler == nil {
+		opts.Sampler = RegularSampler{}
+	}
+	if opts.TileSize <= 0 {
+		opts.TileSize = DEFAULT_TILE_SIZE
+	}
+	if opts.Filter == nil {
+		opts.Filter = DefaultFilter()
+	}
+	opts.Display = opts.Display.withDefaults()
+	return opts
 }
 
-func (j Job) Run(c chan JobResult) {
-	pixels := make([]*color.RGBA, 0, j.End-j.Start)
-	for i := j.Start; i < j.End; i++ {
-		x := i % j.Scene.Camera.Width
-		y := i / j.Scene.Camera.Width
-		pixel := j.Scene.RenderPixel(x, y)
-		pixels = append(pixels, pixel)
+// state shared by the workers rendering an image
+type renderer struct {
+	scene *Scene
+	opts  RenderOptions
+	// accumulates the samples from all tiles and passes
+	film     film
+	filmLock sync.Mutex
+	// number of samples taken for each pixel so far, indexed by y * width + x
+	counts []int
+	// sampler's generator for each pixel, kept between passes so that later passes continue where earlier ones left off
+	// if nil, each pixel's generator is created when it is rendered
+	rngs []RNG
+}
+
+// prepare to render the scene; s is modified to build its acceleration structure
+func newRenderer(s *Scene, opts RenderOptions, keepRNGs bool) *renderer {
+	s.accel = buildSceneAccel(s.Objects)
+	width, height := s.Camera.Width, s.Camera.Height
+	r := renderer{
+		scene:  s,
+		opts:   opts.withDefaults(),
+		film:   makeFilm(image.Rect(0, 0, width, height)),
+		counts: make([]int, width*height),
+	}
+	if keepRNGs {
+		r.rngs = make([]RNG, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				r.rngs[y*width+x] = pixelRNG(r.opts.Seed, x, y)
+			}
+		}
+	}
+	return &r
+}
+
+// render the pixels in a tile of the image, adding their samples to f
+// samples may contribute to pixels outside the tile, up to the filter's support, so f should cover that area as well
+func (r *renderer) renderTile(f *film, tile image.Rectangle, first int) {
+	width := r.scene.Camera.Width
+	var rngs pixelRNGs
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			i := y*width + x
+			if r.rngs != nil {
+				rngs.sampler = r.rngs[i]
+			} else {
+				rngs.sampler = pixelRNG(r.opts.Seed, x, y)
+			}
+			r.counts[i] += r.scene.samplePixel(f, x, y, &r.opts, &rngs, first)
+			if r.rngs != nil {
+				r.rngs[i] = rngs.sampler
+			}
+		}
+	}
+}
+
+// render one pass over the image, with a pool of workers taking tiles from a shared queue
+// first is the index of the first sample taken for each pixel in this pass
+// returns whether all tiles were rendered before ctx was cancelled
+func (r *renderer) pass(ctx context.Context, first int) bool {
+	bounds := r.film.bounds
+	// all tiles are queued up front; each worker takes the next one as soon as it's free
+	allTiles := tiles(bounds.Dx(), bounds.Dy(), r.opts.TileSize, r.opts.TileOrder)
+	queue := make(chan image.Rectangle, len(allTiles))
+	for _, tile := range allTiles {
+		queue <- tile
+	}
+	close(queue)
+	// workers render each tile into their own film, which also covers the neighboring pixels that its samples spill into,
+	// then add it to the film for the whole image
+	margin := int(math.Ceil(r.opts.Filter.Support()))
+	finished := make(chan image.Rectangle)
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tile := range queue {
+				if ctx.Err() != nil {
+					return
+				}
+				tileFilm := makeFilm(tile.Inset(-margin).Intersect(bounds))
+				r.renderTile(&tileFilm, tile, first)
+				r.filmLock.Lock()
+				r.film.merge(&tileFilm)
+				r.filmLock.Unlock()
+				select {
+				case finished <- tile:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	completed := 0
+	for tile := range finished {
+		completed++
+		if r.opts.Progress != nil {
+			r.opts.Progress(RenderProgress{Completed: completed, Total: len(allTiles), Tile: tile})
+		}
+	}
+	return completed == len(allTiles)
+}
+
+// develop the samples taken so far into an image, as configured by the options' display settings
+func (r *renderer) image() *image.RGBA {
+	return r.film.framebuffer().Image(r.opts.Display)
+}
+
+// the outcome of a render
+type RenderResult struct {
+	// 8-bit image, produced from Radiance
+	Image *image.RGBA
+	// linear radiance of each pixel, which can be turned into images with other display settings without re-rendering
+	Radiance *Framebuffer
+	// number of samples taken for each pixel, indexed by y * width + x; 0 for pixels that weren't rendered
+	SampleCounts []int
+}
+
+// render the scene in parallel, with a pool of workers taking tiles from a shared queue, as configured by opts
+// if ctx is cancelled before the render finishes, returns the partially rendered image along with ctx.Err();
+// pixels that no samples reached are left transparent
+func (s Scene) RenderContext(ctx context.Context, opts RenderOptions) (*image.RGBA, error) {
+	result, err := s.RenderWithStats(ctx, opts)
+	return result.Image, err
+}
+
+// same as RenderContext, but also returns statistics about the render
+func (s Scene) RenderWithStats(ctx context.Context, opts RenderOptions) (RenderResult, error) {
+	r := newRenderer(&s, opts, false)
+	done := r.pass(ctx, 0)
+	radiance := r.film.framebuffer()
+	result := RenderResult{
+		Image:        radiance.Image(r.opts.Display),
+		Radiance:     radiance,
+		SampleCounts: r.counts,
+	}
+	if !done {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// render the scene as with RenderContext, but return linear radiance rather than an 8-bit image
+// the options' display settings are only used to judge noise for adaptive sampling
+func (s Scene) RenderHDR(ctx context.Context, opts RenderOptions) (*Framebuffer, error) {
+	result, err := s.RenderWithStats(ctx, opts)
+	return result.Radiance, err
+}
+
+// options for a progressive render, which renders the image repeatedly, improving it with each pass
+type ProgressiveOptions struct {
+	// options for each pass; Samples is the number of samples per pixel taken in each pass
+	// adaptive sampling isn't supported, so MaxSamples is ignored
+	RenderOptions
+	// stop once each pixel has at least this many samples in total; if 0, there is no limit
+	TargetSamples int
+	// stop after the first pass that finishes after this much time; if 0, there is no limit
+	// passes aren't interrupted, so that every pixel always has the same number of samples
+	TimeBudget time.Duration
+	// if not nil, called after each pass with the image so far and the number of samples per pixel it has
+	// each snapshot is a new image, which the callback may keep
+	// calls are made one at a time, from the goroutine that started the render
+	Snapshot func(img *image.RGBA, samples int)
+}
+
+// render the scene progressively, accumulating passes until the target number of samples or the time budget is reached
+// if neither is set, renders until ctx is cancelled
+// if ctx is cancelled, returns the image so far, including any tiles of the interrupted pass that were finished, along with ctx.Err()
+func (s Scene) RenderProgressive(ctx context.Context, opts ProgressiveOptions) (*image.RGBA, error) {
+	opts.MaxSamples = 0
+	r := newRenderer(&s, opts.RenderOptions, true)
+	timeStart := time.Now()
+	samples := 0
+	for opts.TargetSamples <= 0 || samples < opts.TargetSamples {
+		if !r.pass(ctx, samples) {
+			return r.image(), ctx.Err()
+		}
+		samples += r.opts.Samples
+		if opts.Snapshot != nil {
+			opts.Snapshot(r.image(), samples)
+		}
+		if opts.TimeBudget > 0 && time.Since(timeStart) >= opts.TimeBudget {
+			break
+		}
+	}
+	return r.image(), nil
+}
+
+// visualize the number of samples taken for each pixel, from black for the fewest, through red and yellow, to white for the most
+func (r RenderResult) SampleHeatmap() *image.RGBA {
+	bounds := r.Image.Bounds()
+	heatmap := image.NewRGBA(bounds)
+	low, high := math.MaxInt, 0
+	for _, n := range r.SampleCounts {
+		if n < low {
+			low = n
+		}
+		if n > high {
+			high = n
+		}
+	}
+	for i, n := range r.SampleCounts {
+		t := 0.
+		if high > low {
+			t = float64(n-low) / float64(high-low)
+		}
+		// ramp up red, then green, then blue
+		c := Vector{3 * t, 3*t - 1, 3*t - 2}.Trim(0, 1)
+		heatmap.SetRGBA(bounds.Min.X+i%bounds.Dx(), bounds.Min.Y+i/bounds.Dx(), c.toRGBA())
 	}
-	c <- JobResult{Pixels: pixels, Start: j.Start}
+	return heatmap
 }
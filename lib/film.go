@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"image"
+	"math"
+)
+
+// filters with negative lobes can make the sum of a pixel's weights tiny or negative, and dividing by it would blow up the pixel's color
+// pixels whose weights sum to less than this are instead filtered using only their positively weighted samples
+const MIN_FILM_WEIGHT float64 = 1e-3
+
+// accumulates filtered samples for a region of an image
+type film struct {
+	bounds image.Rectangle
+	// weighted sum of the samples contributing to each pixel, and the sum of their weights
+	color  []Vector
+	weight []float64
+	// the same sums, for only the samples with positive weights
+	positiveColor  []Vector
+	positiveWeight []float64
+	// componentwise minimum and maximum of the samples contributing to each pixel
+	// filtered colors are clamped to this range, so that negative lobes can't ring past the samples' values
+	low, high []Vector
+}
+
+func makeFilm(bounds image.Rectangle) film {
+	n := bounds.Dx() * bounds.Dy()
+	f := film{
+		bounds:         bounds,
+		color:          make([]Vector, n),
+		weight:         make([]float64, n),
+		positiveColor:  make([]Vector, n),
+		positiveWeight: make([]float64, n),
+		low:            make([]Vector, n),
+		high:           make([]Vector, n),
+	}
+	for i := range f.low {
+		f.low[i] = Vector{math.Inf(1), math.Inf(1), math.Inf(1)}
+		f.high[i] = Vector{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	}
+	return f
+}
+
+// widen a pixel's range of sample values to include the range from low to high
+func (f *film) include(i int, low, high Vector) {
+	f.low[i] = Vector{math.Min(f.low[i].X, low.X), math.Min(f.low[i].Y, low.Y), math.Min(f.low[i].Z, low.Z)}
+	f.high[i] = Vector{math.Max(f.high[i].X, high.X), math.Max(f.high[i].Y, high.Y), math.Max(f.high[i].Z, high.Z)}
+}
+
+func (f *film) index(x, y int) int {
+	return (y-f.bounds.Min.Y)*f.bounds.Dx() + (x - f.bounds.Min.X)
+}
+
+// add a sample at (x, y), in pixel coordinates, to each pixel within the filter's support
+func (f *film) splat(x, y float64, c Vector, filter Filter) {
+	r := filter.Support()
+	x0 := int(math.Max(math.Ceil(x-r), float64(f.bounds.Min.X)))
+	x1 := int(math.Min(math.Floor(x+r), float64(f.bounds.Max.X-1)))
+	y0 := int(math.Max(math.Ceil(y-r), float64(f.bounds.Min.Y)))
+	y1 := int(math.Min(math.Floor(y+r), float64(f.bounds.Max.Y-1)))
+	for py := y0; py <= y1; py++ {
+		for px := x0; px <= x1; px++ {
+			w := filter.Weight(x-float64(px), y-float64(py))
+			if w == 0 {
+				continue
+			}
+			i := f.index(px, py)
+			f.color[i] = f.color[i].Add(c.MulScalar(w))
+			f.weight[i] += w
+			if w > 0 {
+				f.positiveColor[i] = f.positiveColor[i].Add(c.MulScalar(w))
+				f.positiveWeight[i] += w
+			}
+			f.include(i, c, c)
+		}
+	}
+}
+
+// add the samples in another film to this one, where they overlap
+func (f *film) merge(g *film) {
+	overlap := f.bounds.Intersect(g.bounds)
+	for y := overlap.Min.Y; y < overlap.Max.Y; y++ {
+		for x := overlap.Min.X; x < overlap.Max.X; x++ {
+			i, j := f.index(x, y), g.index(x, y)
+			f.color[i] = f.color[i].Add(g.color[j])
+			f.weight[i] += g.weight[j]
+			f.positiveColor[i] = f.positiveColor[i].Add(g.positiveColor[j])
+			f.positiveWeight[i] += g.positiveWeight[j]
+			f.include(i, g.low[j], g.high[j])
+		}
+	}
+}
+
+// get the filtered color of the pixel at index i, and whether enough samples contributed to it
+func (f *film) filtered(i int) (Vector, bool) {
+	var c Vector
+	if f.weight[i] >= MIN_FILM_WEIGHT {
+		c = f.color[i].MulScalar(1 / f.weight[i])
+	} else if f.positiveWeight[i] > 0 {
+		c = f.positiveColor[i].MulScalar(1 / f.positiveWeight[i])
+	} else {
+		return Zero(), false
+	}
+	low, high := f.low[i], f.high[i]
+	return Vector{
+		math.Min(math.Max(c.X, low.X), high.X),
+		math.Min(math.Max(c.Y, low.Y), high.Y),
+		math.Min(math.Max(c.Z, low.Z), high.Z),
+	}, true
+}
+
+// get the filtered color of a pixel, and whether enough samples contributed to it
+func (f *film) pixel(x, y int) (Vector, bool) {
+	return f.filtered(f.index(x, y))
+}
+
+// get the filtered radiance of each pixel in the film
+func (f *film) framebuffer() *Framebuffer {
+	fb := NewFramebuffer(f.bounds)
+	for i := range f.weight {
+		fb.Radiance[i], fb.Rendered[i] = f.filtered(i)
+	}
+	return fb
+}
@@ -0,0 +1,130 @@
+package lib
+
+import "math"
+
+// reconstruction filter, which weights how much a sample contributes to each pixel near it
+type Filter interface {
+	// max horizontal or vertical distance, in pixels, from a sample to the pixels it contributes to
+	Support() float64
+	// weight of a sample at offset (dx, dy) from a pixel's center
+	Weight(dx, dy float64) float64
+}
+
+// filter used if none is given: each sample only contributes to the pixel it lies in, and all samples are weighted equally
+func DefaultFilter() Filter {
+	return BoxFilter{Radius: 0.5}
+}
+
+// weights all samples within Radius equally
+type BoxFilter struct {
+	Radius float64
+}
+
+func (f BoxFilter) Support() float64 {
+	return f.Radius
+}
+
+func (f BoxFilter) Weight(dx, dy float64) float64 {
+	// the box is half-open, so that with radius 0.5, a sample on the border between pixels counts toward exactly one of them
+	if dx < -f.Radius || dx >= f.Radius || dy < -f.Radius || dy >= f.Radius {
+		return 0
+	}
+	return 1
+}
+
+// weights samples by a triangle that falls linearly from 1 at the center to 0 at Radius; 1 is a typical radius
+type TentFilter struct {
+	Radius float64
+}
+
+func (f TentFilter) Support() float64 {
+	return f.Radius
+}
+
+func (f TentFilter) Weight(dx, dy float64) float64 {
+	return tent(dx, f.Radius) * tent(dy, f.Radius)
+}
+
+func tent(d, radius float64) float64 {
+	return math.Max(0, 1-math.Abs(d)/radius)
+}
+
+// weights samples by a Gaussian with standard deviation Sigma, shifted down so that it reaches 0 at Radius
+// e.g. Radius 1.5 and Sigma 0.5
+type GaussianFilter struct {
+	Radius, Sigma float64
+}
+
+func (f GaussianFilter) Support() float64 {
+	return f.Radius
+}
+
+func (f GaussianFilter) Weight(dx, dy float64) float64 {
+	return f.gaussian(dx) * f.gaussian(dy)
+}
+
+func (f GaussianFilter) gaussian(d float64) float64 {
+	edge := math.Exp(-f.Radius * f.Radius / (2 * f.Sigma * f.Sigma))
+	return math.Max(0, math.Exp(-d*d/(2*f.Sigma*f.Sigma))-edge)
+}
+
+// Mitchell-Netravali cubic filter, which balances blurring (higher B) against ringing (higher C)
+// the filter has negative lobes, which sharpen edges
+type MitchellFilter struct {
+	Radius, B, C float64
+}
+
+// Mitchell-Netravali filter with the recommended parameters B = C = 1/3
+func MakeMitchellFilter(radius float64) MitchellFilter {
+	return MitchellFilter{Radius: radius, B: 1. / 3, C: 1. / 3}
+}
+
+func (f MitchellFilter) Support() float64 {
+	return f.Radius
+}
+
+func (f MitchellFilter) Weight(dx, dy float64) float64 {
+	return f.mitchell(dx) * f.mitchell(dy)
+}
+
+func (f MitchellFilter) mitchell(d float64) float64 {
+	// the cubic is defined on [-2, 2], so scale it to the filter's radius
+	t := 2 * math.Abs(d) / f.Radius
+	b, c := f.B, f.C
+	switch {
+	case t < 1:
+		return ((12-9*b-6*c)*t*t*t + (-18+12*b+6*c)*t*t + (6 - 2*b)) / 6
+	case t < 2:
+		return ((-b-6*c)*t*t*t + (6*b+30*c)*t*t + (-12*b-48*c)*t + (8*b + 24*c)) / 6
+	}
+	return 0
+}
+
+// windowed sinc filter, which keeps images sharp at the cost of some ringing around edges; 2 or 3 is a typical radius
+type LanczosFilter struct {
+	Radius float64
+}
+
+func (f LanczosFilter) Support() float64 {
+	return f.Radius
+}
+
+func (f LanczosFilter) Weight(dx, dy float64) float64 {
+	return f.lanczos(dx) * f.lanczos(dy)
+}
+
+func (f LanczosFilter) lanczos(d float64) float64 {
+	if math.Abs(d) >= f.Radius {
+		return 0
+	}
+	return sinc(d) * sinc(d/f.Radius)
+}
+
+// normalized sinc function, sin(pi x) / (pi x)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
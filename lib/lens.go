@@ -0,0 +1,38 @@
+package lib
+
+import "math"
+
+// pick a uniformly distributed point on the camera's aperture, relative to its center
+// returns offsets along the camera's Right and Up directions
+func (c Camera) lensOffset(rng *RNG) (float64, float64) {
+	var x, y float64
+	if c.ApertureBlades < 3 {
+		x, y = sampleDisk(rng.Float64(), rng.Float64())
+	} else {
+		x, y = samplePolygon(c.ApertureBlades, c.ApertureRotation, rng.Float64(), rng.Float64(), rng.Float64())
+	}
+	return x * c.Aperture, y * c.Aperture
+}
+
+// map a point in the unit square to a uniformly distributed point in the unit disk
+func sampleDisk(u, v float64) (float64, float64) {
+	r := math.Sqrt(u)
+	theta := 2 * math.Pi * v
+	return r * math.Cos(theta), r * math.Sin(theta)
+}
+
+// map random numbers in [0, 1) to a uniformly distributed point in a regular polygon inscribed in the unit circle
+func samplePolygon(sides int, rotation, u, v, w float64) (float64, float64) {
+	// the polygon is made up of equal triangles around its center, so pick one, then pick a point in it
+	side := int(u * float64(sides))
+	if side >= sides {
+		side = sides - 1
+	}
+	a1 := rotation + 2*math.Pi*float64(side)/float64(sides)
+	a2 := rotation + 2*math.Pi*float64(side+1)/float64(sides)
+	// fold points in the unit square into the triangle between the center and the side's corners
+	if v+w > 1 {
+		v, w = 1-v, 1-w
+	}
+	return v*math.Cos(a1) + w*math.Cos(a2), v*math.Sin(a1) + w*math.Sin(a2)
+}
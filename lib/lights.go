@@ -0,0 +1,408 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+)
+
+// a source of light in a scene
+type Light interface {
+	// get the light arriving at point p from the light, or false if none does
+	// lights with an area pick a point on themselves using rng, so that their shadows have soft edges
+	Sample(p Vector, rng *RNG) (LightSample, bool)
+}
+
+// implemented by lights that can be seen directly, as a glow around them, by rays that don't hit any object
+type HaloLight interface {
+	Light
+	Halo(r Ray) Vector
+}
+
+// implemented by lights with colors that can be converted between color spaces, e.g. when loading scene files
+type ColoredLight interface {
+	Light
+	// get a copy of the light with each of its colors passed through convert
+	MapColors(convert func(Vector) Vector) Light
+}
+
+// implemented by lights that should be sampled more than once for each hit, to reduce noise in their shadows
+type multiSampledLight interface {
+	sampleCount() int
+}
+
+// how a light's intensity changes with distance from it
+type Falloff int
+
+const (
+	// light is equally strong at any distance
+	NO_FALLOFF Falloff = iota
+	// intensity falls linearly, from full at the light to none at its falloff radius
+	LINEAR_FALLOFF
+	// intensity falls with the square of the distance, as for real lights, and is full within the light's falloff radius
+	INVERSE_SQUARE_FALLOFF
+)
+
+var falloffNames = map[Falloff]string{
+	NO_FALLOFF:             "none",
+	LINEAR_FALLOFF:         "linear",
+	INVERSE_SQUARE_FALLOFF: "inverse-square",
+}
+
+func (f Falloff) String() string {
+	if name, ok := falloffNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Falloff(%d)", int(f))
+}
+
+// get the falloff with the given name, as returned by Falloff.String
+func ParseFalloff(name string) (Falloff, error) {
+	for falloff, n := range falloffNames {
+		if n == name {
+			return falloff, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown falloff %q; expected none, linear or inverse-square", name)
+}
+
+// falloffs are written by name in scene files
+func (f Falloff) MarshalText() ([]byte, error) {
+	if _, ok := falloffNames[f]; !ok {
+		return nil, fmt.Errorf("unknown falloff %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+func (f *Falloff) UnmarshalText(text []byte) error {
+	falloff, err := ParseFalloff(string(text))
+	if err != nil {
+		return err
+	}
+	*f = falloff
+	return nil
+}
+
+// brightness and color of a light, shared by the lights that are at a finite distance
+type LightEmission struct {
+	Intensity float64
+	// color of the light, multiplied by Intensity; scene files without a color use white
+	Color Vector
+	// how the light gets weaker with distance
+	Falloff Falloff
+	// distance over which the light falls off; see Falloff; if 0, uses 1
+	FalloffRadius float64
+}
+
+// white light of the given intensity, equally strong at any distance
+func MakeLightEmission(intensity float64) LightEmission {
+	return LightEmission{Intensity: intensity, Color: White()}
+}
+
+// fraction of the light's intensity that reaches the given distance from it
+func (e LightEmission) attenuation(distance float64) float64 {
+	radius := e.FalloffRadius
+	if radius <= 0 {
+		radius = 1
+	}
+	switch e.Falloff {
+	case LINEAR_FALLOFF:
+		return math.Max(1-distance/radius, 0)
+	case INVERSE_SQUARE_FALLOFF:
+		if distance <= radius {
+			return 1
+		}
+		return radius * radius / (distance * distance)
+	}
+	return 1
+}
+
+// light arriving at the given distance from the light
+func (e LightEmission) Radiance(distance float64) Vector {
+	return e.Color.MulScalar(e.Intensity * e.attenuation(distance))
+}
+
+// distance from a light within which its halo is visible
+func haloThreshold(intensity float64) float64 {
+	if intensity <= 0 {
+		// light has no halo
+		return 0
+	}
+	return -math.Log(HALO_THRESHOLD/intensity) / HALO_DROPOFF
+}
+
+// glow seen by a ray passing within threshold of a point light at position
+func halo(r Ray, position Vector, threshold float64, e LightEmission) Vector {
+	// get scalar projection of ray connecting light and camera onto ray
+	// if this is negative, the light is behind the camera
+	cameraToLight := position.Sub(r.Origin)
+	scalarProjection := cameraToLight.Dot(r.Direction.Vector)
+	if scalarProjection < 0 {
+		return Zero()
+	}
+	// get distance between projected ray and light; use this to calculate light intensity
+	distance := math.Sqrt(cameraToLight.Dot(cameraToLight) - scalarProjection*scalarProjection)
+	if distance > threshold {
+		return Zero()
+	}
+	intensity := e.Intensity * math.Exp(-HALO_DROPOFF*distance)
+	return e.Color.MulScalar(intensity)
+}
+
+// light coming from a single point toward p
+func pointSample(p, position Vector, e LightEmission) LightSample {
+	toLight := position.Sub(p)
+	distance := math.Sqrt(toLight.Dot(toLight))
+	return LightSample{toLight.Unit(), distance, e.Radiance(distance)}
+}
+
+// light shining equally in all directions from a single point, which casts hard shadows
+type PointLight struct {
+	Position Vector
+	// distance from the light within which its halo is visible; MakeLight sets this from the intensity
+	Threshold float64
+	LightEmission
+}
+
+// white point light at the given position, equally strong at any distance
+func MakeLight(position Vector, intensity float64) PointLight {
+	return PointLight{Position: position, Threshold: haloThreshold(intensity), LightEmission: MakeLightEmission(intensity)}
+}
+
+func (l PointLight) Sample(p Vector, rng *RNG) (LightSample, bool) {
+	return pointSample(p, l.Position, l.LightEmission), true
+}
+
+func (l PointLight) Halo(r Ray) Vector {
+	return halo(r, l.Position, l.Threshold, l.LightEmission)
+}
+
+func (l PointLight) MapColors(convert func(Vector) Vector) Light {
+	l.Color = convert(l.Color)
+	return l
+}
+
+// point light shining in a cone, like a flashlight or stage light
+// the light is full strength within InnerAngle of Direction, and fades out smoothly by OuterAngle
+type SpotLight struct {
+	Position  Vector
+	Direction Vector
+	// angles from Direction, in radians; if OuterAngle is less than InnerAngle, the edge of the cone is sharp
+	InnerAngle, OuterAngle float64
+	// distance from the light within which its halo is visible; MakeSpotLight sets this from the intensity
+	Threshold float64
+	LightEmission
+}
+
+// white spot light, equally strong at any distance within its cone
+func MakeSpotLight(position, direction Vector, innerAngle, outerAngle, intensity float64) SpotLight {
+	return SpotLight{
+		Position:      position,
+		Direction:     direction,
+		InnerAngle:    innerAngle,
+		OuterAngle:    outerAngle,
+		Threshold:     haloThreshold(intensity),
+		LightEmission: MakeLightEmission(intensity),
+	}
+}
+
+// fraction of the light's intensity shining along the given direction
+func (l SpotLight) cone(direction Vector) float64 {
+	cosInner := math.Cos(l.InnerAngle)
+	cosOuter := math.Cos(math.Max(l.OuterAngle, l.InnerAngle))
+	cos := direction.Dot(l.Direction.Unit().Vector)
+	if cos >= cosInner {
+		return 1
+	}
+	if cos <= cosOuter {
+		return 0
+	}
+	t := (cos - cosOuter) / (cosInner - cosOuter)
+	// smoothstep, so that the edge of the cone doesn't show a visible ring
+	return t * t * (3 - 2*t)
+}
+
+func (l SpotLight) Sample(p Vector, rng *RNG) (LightSample, bool) {
+	sample := pointSample(p, l.Position, l.LightEmission)
+	cone := l.cone(sample.Direction.MulScalar(-1))
+	if cone == 0 {
+		return LightSample{}, false
+	}
+	sample.Radiance = sample.Radiance.MulScalar(cone)
+	return sample, true
+}
+
+func (l SpotLight) Halo(r Ray) Vector {
+	return halo(r, l.Position, l.Threshold, l.LightEmission)
+}
+
+func (l SpotLight) MapColors(convert func(Vector) Vector) Light {
+	l.Color = convert(l.Color)
+	return l
+}
+
+// light arriving from infinitely far away in a single direction, like sunlight
+// it is equally strong everywhere, and casts parallel shadows
+type DirectionalLight struct {
+	// direction in which the light travels, e.g. downward for a sun overhead
+	Direction Vector
+	Intensity float64
+	Color     Vector
+}
+
+func MakeDirectionalLight(direction Vector, intensity float64) DirectionalLight {
+	return DirectionalLight{Direction: direction, Intensity: intensity, Color: White()}
+}
+
+func (l DirectionalLight) Sample(p Vector, rng *RNG) (LightSample, bool) {
+	return LightSample{l.Direction.MulScalar(-1).Unit(), math.Inf(1), l.Color.MulScalar(l.Intensity)}, true
+}
+
+func (l DirectionalLight) MapColors(convert func(Vector) Vector) Light {
+	l.Color = convert(l.Color)
+	return l
+}
+
+// number of points sampled on an area light for each hit, given its Samples field
+func areaSamples(samples int) int {
+	if samples < 1 {
+		return 1
+	}
+	return samples
+}
+
+// light sampled at a point on a surface, which emits toward the side its normal faces
+// the light is as strong as a point light of the same intensity when seen face on, and weaker when seen at an angle
+func surfaceSample(p, point Vector, normal Vector, e LightEmission) (LightSample, bool) {
+	sample := pointSample(p, point, e)
+	cos := -sample.Direction.Dot(normal)
+	if cos <= 0 {
+		return LightSample{}, false
+	}
+	sample.Radiance = sample.Radiance.MulScalar(cos)
+	return sample, true
+}
+
+// get two unit vectors perpendicular to n and to each other
+func perpendicular(n Vector) (Vector, Vector) {
+	// cross with the axis least aligned with n, to avoid a degenerate result
+	axis := Vector{1, 0, 0}
+	if math.Abs(n.X) > 0.9 {
+		axis = Vector{0, 1, 0}
+	}
+	u := n.Cross(axis).Unit().Vector
+	return u, n.Cross(u)
+}
+
+// rectangular light, like a window or light panel, which casts soft shadows
+type RectLight struct {
+	Center Vector
+	// the rectangle's sides, which should be perpendicular; light is emitted on the side that U x V points toward
+	U, V Vector
+	// number of points sampled on the light for each hit; more give smoother shadows; if 0, uses 1
+	Samples int
+	LightEmission
+}
+
+func (l RectLight) Sample(p Vector, rng *RNG) (LightSample, bool) {
+	s, t := rng.Float64()-0.5, rng.Float64()-0.5
+	point := l.Center.Add(l.U.MulScalar(s)).Add(l.V.MulScalar(t))
+	return surfaceSample(p, point, l.U.Cross(l.V).Unit().Vector, l.LightEmission)
+}
+
+func (l RectLight) sampleCount() int {
+	return areaSamples(l.Samples)
+}
+
+func (l RectLight) MapColors(convert func(Vector) Vector) Light {
+	l.Color = convert(l.Color)
+	return l
+}
+
+// round light, like a ceiling light, which casts soft shadows
+type DiskLight struct {
+	Center Vector
+	// direction that the light faces
+	Normal Vector
+	Radius float64
+	// number of points sampled on the light for each hit; more give smoother shadows; if 0, uses 1
+	Samples int
+	LightEmission
+}
+
+func (l DiskLight) Sample(p Vector, rng *RNG) (LightSample, bool) {
+	normal := l.Normal.Unit().Vector
+	u, v := perpendicular(normal)
+	x, y := sampleDisk(rng.Float64(), rng.Float64())
+	point := l.Center.Add(u.MulScalar(x * l.Radius)).Add(v.MulScalar(y * l.Radius))
+	return surfaceSample(p, point, normal, l.LightEmission)
+}
+
+func (l DiskLight) sampleCount() int {
+	return areaSamples(l.Samples)
+}
+
+func (l DiskLight) MapColors(convert func(Vector) Vector) Light {
+	l.Color = convert(l.Color)
+	return l
+}
+
+// glowing ball, like a light bulb, which shines in all directions and casts soft shadows
+type SphereLight struct {
+	Center Vector
+	Radius float64
+	// number of points sampled on the light for each hit; more give smoother shadows; if 0, uses 1
+	Samples int
+	// distance from the light's center within which its halo is visible; if 0, there is no halo
+	// MakeSphereLight sets this from the intensity
+	Threshold float64
+	LightEmission
+}
+
+// white sphere light, equally strong at any distance, sampled once per hit
+func MakeSphereLight(center Vector, radius, intensity float64) SphereLight {
+	return SphereLight{
+		Center:        center,
+		Radius:        radius,
+		Threshold:     haloThreshold(intensity),
+		LightEmission: MakeLightEmission(intensity),
+	}
+}
+
+func (l SphereLight) Sample(p Vector, rng *RNG) (LightSample, bool) {
+	toCenter := l.Center.Sub(p)
+	distSq := toCenter.Dot(toCenter)
+	radiusSq := l.Radius * l.Radius
+	if distSq <= radiusSq {
+		// p is inside the light, so light arrives from every direction; pick one uniformly
+		z := 1 - 2*rng.Float64()
+		r, phi := math.Sqrt(math.Max(1-z*z, 0)), 2*math.Pi*rng.Float64()
+		direction := Vector{r * math.Cos(phi), r * math.Sin(phi), z}.Unit()
+		return LightSample{direction, 0, l.Radiance(0)}, true
+	}
+	// pick a direction uniformly within the cone that the sphere covers, as seen from p
+	dist := math.Sqrt(distSq)
+	w := toCenter.MulScalar(1 / dist)
+	u, v := perpendicular(w)
+	cosMax := math.Sqrt(1 - radiusSq/distSq)
+	cos := 1 - rng.Float64()*(1-cosMax)
+	sin := math.Sqrt(math.Max(1-cos*cos, 0))
+	phi := 2 * math.Pi * rng.Float64()
+	direction := w.MulScalar(cos).Add(u.MulScalar(sin * math.Cos(phi))).Add(v.MulScalar(sin * math.Sin(phi))).Unit()
+	// distance along the direction to the near side of the sphere
+	along := direction.Dot(toCenter)
+	distance := along - math.Sqrt(math.Max(radiusSq-(distSq-along*along), 0))
+	return LightSample{direction, distance, l.Radiance(distance)}, true
+}
+
+func (l SphereLight) sampleCount() int {
+	return areaSamples(l.Samples)
+}
+
+func (l SphereLight) Halo(r Ray) Vector {
+	return halo(r, l.Center, l.Threshold, l.LightEmission)
+}
+
+func (l SphereLight) MapColors(convert func(Vector) Vector) Light {
+	l.Color = convert(l.Color)
+	return l
+}
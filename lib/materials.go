@@ -0,0 +1,220 @@
+package lib
+
+import "math"
+
+// information about a ray hitting the surface of an object
+type Hit struct {
+	// the ray that hit the surface
+	Ray Ray
+	// location of the hit
+	Point Vector
+	// normal vector of the surface at the hit, as given by the object's shape
+	Normal unitVector
+	// texture coordinates at the hit, as given by the object's shape
+	TexCoord TexCoord
+	scene    *Scene
+	// for random choices while shading, such as points on area lights
+	rng *RNG
+}
+
+// whether the ray hit the back of the surface, i.e. is leaving the object it was inside
+func (h Hit) Inside() bool {
+	return h.Ray.Direction.Dot(h.Normal.Vector) > 0
+}
+
+// light arriving at a hit from one of the scene's lights
+type LightSample struct {
+	// direction from the hit toward the light
+	Direction unitVector
+	// distance to the light, which is infinite for lights such as DirectionalLight
+	Distance float64
+	Radiance Vector
+}
+
+// get the light arriving at the hit from each of the scene's lights that isn't blocked by another object
+// lights with an area may give several samples, from different points on the light, whose radiance adds up to the light's total
+func (h Hit) Lights() []LightSample {
+	if h.scene == nil {
+		return nil
+	}
+	rng := h.rng
+	if rng == nil {
+		fixed := MakeRNG(0)
+		rng = &fixed
+	}
+	var samples []LightSample
+	for _, light := range h.scene.Lights {
+		n := 1
+		if multi, ok := light.(multiSampledLight); ok {
+			n = multi.sampleCount()
+		}
+		for i := 0; i < n; i++ {
+			sample, ok := light.Sample(h.Point, rng)
+			if !ok {
+				continue
+			}
+			if h.scene.occluded(Ray{Origin: h.Point, Direction: sample.Direction, Time: h.Ray.Time}, sample.Distance) {
+				continue
+			}
+			if n > 1 {
+				sample.Radiance = sample.Radiance.MulScalar(1 / float64(n))
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// a secondary ray spawned by a material, whose traced light is multiplied by Weight
+// the ray's time is ignored; it is traced at the same time as the ray that hit the surface
+type ScatteredRay struct {
+	Ray    Ray
+	Weight Vector
+}
+
+// describes how light interacts with the surface of an object
+type Material interface {
+	// light emitted by the surface itself toward the ray origin
+	Emission(h Hit) Vector
+	// light scattered toward the ray origin, consisting of light arriving directly from the scene's lights (see Hit.Lights),
+	// plus secondary rays that should be traced to find light arriving from other directions
+	Scatter(h Hit) (Vector, []ScatteredRay)
+	// factor applied to all light leaving the hit toward the ray origin, e.g. due to absorption inside an object
+	Attenuation(h Hit) Vector
+}
+
+// implemented by materials with colors that can be converted between color spaces, e.g. when loading scene files
+type ColoredMaterial interface {
+	Material
+	// get a copy of the material with each of its colors passed through convert
+	MapColors(convert func(Vector) Vector) Material
+}
+
+// material with ambient, diffuse and mirror-like reflection, highlights, and optional transparency
+type ClassicMaterial struct {
+	// all in range [0, 1]
+	// specular is the fraction of light mirrored by the surface
+	Ambient, Diffuse, Specular float64
+	Color                      Vector
+	// strength of highlights from lights in the scene, in range [0, 1]
+	Highlight float64
+	// exponent controlling the size of highlights; higher values give smaller, sharper highlights
+	Shininess float64
+	// fraction of light passing through the surface, in range [0, 1]
+	Transparency float64
+	// index of refraction of the material inside the object; values below 1 are treated as 1
+	RefractiveIndex float64
+	// fraction of each color channel absorbed per unit of distance travelled inside the object
+	Absorption Vector
+}
+
+// only Color is converted; Absorption is a rate rather than a color
+func (m ClassicMaterial) MapColors(convert func(Vector) Vector) Material {
+	m.Color = convert(m.Color)
+	return m
+}
+
+// light that doesn't pass through the surface is scattered at it
+func (m ClassicMaterial) opacity() float64 {
+	return 1 - m.Transparency
+}
+
+func (m ClassicMaterial) Emission(h Hit) Vector {
+	return m.Color.MulScalar(m.Ambient * m.opacity())
+}
+
+func (m ClassicMaterial) Scatter(h Hit) (Vector, []ScatteredRay) {
+	if h.Inside() && m.Transparency == 0 {
+		// an opaque surface has no inside to leave, so its back is seen only on open geometry, such as a lone triangle;
+		// shade it like the front
+		h.Normal = unitVector{h.Normal.MulScalar(-1)}
+	}
+	color := Zero()
+	diffuse := m.Diffuse > 0 && m.opacity() > 0
+	highlight := m.Highlight > 0 && !h.Inside()
+	if diffuse || highlight {
+		lights := h.Lights()
+		if diffuse {
+			diffusion := Zero()
+			for _, light := range lights {
+				if cos := h.Normal.Dot(light.Direction.Vector); cos > 0 {
+					diffusion = diffusion.Add(light.Radiance.MulScalar(cos))
+				}
+			}
+			diffuseColor := m.Color.Mul(diffusion.MulScalar(m.Diffuse * m.opacity()))
+			color = color.Add(diffuseColor)
+		}
+		if highlight {
+			highlightColor := m.highlight(h, lights).MulScalar(m.Highlight)
+			color = color.Add(highlightColor)
+		}
+	}
+	var rays []ScatteredRay
+	if m.Specular > 0 {
+		reflection := h.Ray.Direction.Reflect(h.Normal.Vector).Unit()
+		reflectionRay := Ray{Origin: h.Point, Direction: reflection}
+		rays = append(rays, ScatteredRay{reflectionRay, Vector{m.Specular, m.Specular, m.Specular}})
+	}
+	if m.Transparency > 0 {
+		rays = append(rays, m.refract(h)...)
+	}
+	return color, rays
+}
+
+func (m ClassicMaterial) Attenuation(h Hit) Vector {
+	if !h.Inside() || m.Absorption == Zero() {
+		return White()
+	}
+	// light reaching the ray origin from here has passed through the object's interior
+	delta := h.Point.Sub(h.Ray.Origin)
+	distance := math.Sqrt(delta.Dot(delta))
+	return m.Absorption.MulScalar(-distance).Exp()
+}
+
+// get highlights from the given lights, using the Blinn-Phong model
+func (m ClassicMaterial) highlight(h Hit, lights []LightSample) Vector {
+	toViewer := h.Ray.Direction.MulScalar(-1)
+	total := Zero()
+	for _, light := range lights {
+		if h.Normal.Dot(light.Direction.Vector) <= 0 {
+			// light is behind the surface
+			continue
+		}
+		halfway := light.Direction.Add(toViewer).Unit()
+		if cos := h.Normal.Dot(halfway.Vector); cos > 0 {
+			total = total.Add(light.Radiance.MulScalar(math.Pow(cos, m.Shininess)))
+		}
+	}
+	return total
+}
+
+// split the light passing through a transparent surface into reflected and refracted rays, weighted according to the Fresnel equations
+func (m ClassicMaterial) refract(h Hit) []ScatteredRay {
+	n1, n2 := 1., math.Max(m.RefractiveIndex, 1)
+	n := h.Normal.Vector
+	if h.Inside() {
+		n1, n2 = n2, n1
+		n = n.MulScalar(-1)
+	}
+	cosI := -h.Ray.Direction.Dot(n)
+	eta := n1 / n2
+	sinTSq := eta * eta * (1 - cosI*cosI)
+	reflectionRay := Ray{Origin: h.Point, Direction: h.Ray.Direction.Reflect(n).Unit()}
+	transparency := Vector{m.Transparency, m.Transparency, m.Transparency}
+	if sinTSq >= 1 {
+		// total internal reflection
+		return []ScatteredRay{{reflectionRay, transparency}}
+	}
+	cosT := math.Sqrt(1 - sinTSq)
+	// average of reflectance for s- and p-polarized light
+	rs := (n1*cosI - n2*cosT) / (n1*cosI + n2*cosT)
+	rp := (n1*cosT - n2*cosI) / (n1*cosT + n2*cosI)
+	reflectance := (rs*rs + rp*rp) / 2
+	refraction := h.Ray.Direction.MulScalar(eta).Add(n.MulScalar(eta*cosI - cosT)).Unit()
+	refractionRay := Ray{Origin: h.Point, Direction: refraction}
+	rays := []ScatteredRay{{refractionRay, transparency.MulScalar(1 - reflectance)}}
+	if reflectance > 0 {
+		rays = append(rays, ScatteredRay{reflectionRay, transparency.MulScalar(reflectance)})
+	}
+	return rays
+}
@@ -0,0 +1,171 @@
+package lib
+
+import "math"
+
+// texture coordinates on a surface
+type TexCoord struct {
+	U, V float64
+}
+
+// a double-sided triangle
+// the normal of a hit on the back isn't flipped, so that Hit.Inside can tell when a ray leaves a closed mesh;
+// opaque materials shade the back like the front
+type Triangle struct {
+	Vertices [3]Vector
+	// per-vertex normals, used to smoothly interpolate shading normals
+	// if left as zero vectors, the triangle is shaded flat
+	Normals [3]Vector
+	// per-vertex texture coordinates
+	UVs [3]TexCoord
+}
+
+// returns the distance along the ray to the triangle, or +Inf if it misses,
+// along with the barycentric coordinates of the intersection relative to the second and third vertices
+func (t Triangle) intersect(r Ray) (float64, float64, float64) {
+	// Möller–Trumbore intersection
+	edge1 := t.Vertices[1].Sub(t.Vertices[0])
+	edge2 := t.Vertices[2].Sub(t.Vertices[0])
+	p := r.Direction.Cross(edge2)
+	det := edge1.Dot(p)
+	if math.Abs(det) < 1e-12 {
+		// ray is parallel to triangle
+		return math.Inf(1), 0, 0
+	}
+	invDet := 1 / det
+	s := r.Origin.Sub(t.Vertices[0])
+	u := s.Dot(p) * invDet
+	if u < 0 || u > 1 {
+		return math.Inf(1), 0, 0
+	}
+	q := s.Cross(edge1)
+	v := r.Direction.Dot(q) * invDet
+	if v < 0 || u+v > 1 {
+		return math.Inf(1), 0, 0
+	}
+	dist := edge2.Dot(q) * invDet
+	if dist < PLANE_TOL {
+		return math.Inf(1), 0, 0
+	}
+	return dist, u, v
+}
+
+func (t Triangle) Intersection(r Ray) SurfaceHit {
+	dist, u, v := t.intersect(r)
+	if math.IsInf(dist, 1) {
+		return Miss()
+	}
+	hit := makeSurfaceHit(r, dist, t.normal(1-u-v, u, v))
+	hit.TexCoord = t.texCoord(1-u-v, u, v)
+	return hit
+}
+
+func (t Triangle) faceNormal() Vector {
+	return t.Vertices[1].Sub(t.Vertices[0]).Cross(t.Vertices[2].Sub(t.Vertices[0]))
+}
+
+func (t Triangle) smooth() bool {
+	return t.Normals != [3]Vector{}
+}
+
+// get the shading normal at the point with the given barycentric coordinates
+func (t Triangle) normal(a, b, c float64) unitVector {
+	if !t.smooth() {
+		return t.faceNormal().Unit()
+	}
+	return t.Normals[0].MulScalar(a).Add(t.Normals[1].MulScalar(b)).Add(t.Normals[2].MulScalar(c)).Unit()
+}
+
+// get the interpolated texture coordinates at the point with the given barycentric coordinates
+func (t Triangle) texCoord(a, b, c float64) TexCoord {
+	return TexCoord{
+		a*t.UVs[0].U + b*t.UVs[1].U + c*t.UVs[2].U,
+		a*t.UVs[0].V + b*t.UVs[1].V + c*t.UVs[2].V,
+	}
+}
+
+func (t Triangle) Bounds() AABB {
+	return AABB{t.Vertices[0], t.Vertices[0]}.AddPoint(t.Vertices[1]).AddPoint(t.Vertices[2])
+}
+
+// indices into the vertex, normal and texture coordinate lists of a mesh
+// normal and texture coordinate indices may be -1 if not available
+type Face struct {
+	Vertices, Normals, TexCoords [3]int
+}
+
+// an indexed triangle mesh, which can be used as a single shape
+// should be created with MakeMesh, which builds the acceleration structure used for intersections;
+// a Mesh built as a literal still works, but tests every face against every ray
+type Mesh struct {
+	Vertices  []Vector
+	Normals   []Vector
+	TexCoords []TexCoord
+	Faces     []Face
+	tree      bvh
+}
+
+func MakeMesh(vertices []Vector, normals []Vector, texCoords []TexCoord, faces []Face) Mesh {
+	m := Mesh{Vertices: vertices, Normals: normals, TexCoords: texCoords, Faces: faces}
+	bounds := make([]AABB, len(faces))
+	for i := range faces {
+		bounds[i] = m.Triangle(i).Bounds()
+	}
+	m.tree = buildBVH(bounds)
+	return m
+}
+
+// returns the i-th face of the mesh as a standalone triangle
+func (m Mesh) Triangle(i int) Triangle {
+	face := m.Faces[i]
+	var t Triangle
+	smooth := true
+	for j := 0; j < 3; j++ {
+		t.Vertices[j] = m.Vertices[face.Vertices[j]]
+		if face.Normals[j] >= 0 && face.Normals[j] < len(m.Normals) {
+			t.Normals[j] = m.Normals[face.Normals[j]]
+		} else {
+			smooth = false
+		}
+		if face.TexCoords[j] >= 0 && face.TexCoords[j] < len(m.TexCoords) {
+			t.UVs[j] = m.TexCoords[face.TexCoords[j]]
+		}
+	}
+	if !smooth {
+		t.Normals = [3]Vector{}
+	}
+	return t
+}
+
+func (m Mesh) Intersection(r Ray) SurfaceHit {
+	faceDist := func(i int) float64 {
+		dist, _, _ := m.Triangle(i).intersect(r)
+		return dist
+	}
+	i := -1
+	if len(m.tree.nodes) > 0 {
+		i, _ = m.tree.closest(r, faceDist)
+	} else {
+		// no tree, since the mesh wasn't made with MakeMesh
+		best := math.Inf(1)
+		for j := range m.Faces {
+			if dist := faceDist(j); dist < best {
+				i, best = j, dist
+			}
+		}
+	}
+	if i < 0 {
+		return Miss()
+	}
+	return m.Triangle(i).Intersection(r)
+}
+
+func (m Mesh) Bounds() AABB {
+	if len(m.tree.nodes) == 0 {
+		b := EmptyAABB()
+		for i := range m.Faces {
+			b = b.Union(m.Triangle(i).Bounds())
+		}
+		return b
+	}
+	return m.tree.nodes[0].bounds
+}
@@ -0,0 +1,27 @@
+package lib
+
+import (
+	"image/color"
+	"testing"
+)
+
+// a lone triangle is lit the same whichever side of it faces the camera and light
+func TestTriangleBothSides(t *testing.T) {
+	a, b, c := Vector{-1, -1, 5}, Vector{1, -1, 5}, Vector{0, 1, 5}
+	material := ClassicMaterial{Diffuse: 0.9, Color: White(), Highlight: 0.5, Shininess: 10}
+	render := func(tri Triangle) color.RGBA {
+		s := Scene{Camera: DefaultCamera(9, 9)}
+		s.Objects = []Object{{Shape: tri, Material: material}}
+		s.Lights = []Light{MakeLight(Vector{0, 0, 1}, 0.8)}
+		return s.RenderPixel(4, 4)
+	}
+	// normal of a, b, c points away from the camera, and of a, c, b toward it
+	back := render(Triangle{Vertices: [3]Vector{a, b, c}})
+	front := render(Triangle{Vertices: [3]Vector{a, c, b}})
+	if front.R == 0 {
+		t.Fatalf("front of triangle is black")
+	}
+	if back != front {
+		t.Errorf("back of triangle is %v, but front is %v", back, front)
+	}
+}
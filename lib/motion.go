@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"math"
+	"sort"
+)
+
+// position of a moving shape at a point in time, relative to where the shape itself says it is
+type Keyframe struct {
+	// in the same units as the camera's shutter times
+	Time float64
+	// distance the shape has moved
+	Offset Vector
+	// angle the shape has turned around the Moving's axis, in radians
+	Angle float64
+}
+
+// a shape that moves over time, e.g. while the camera's shutter is open, giving motion blur
+// between keyframes, the offset and angle are interpolated linearly; before the first keyframe and after the last, the shape stays still
+// linear motion only needs two keyframes, at the shutter's opening and closing times
+type Moving struct {
+	Shape Shape
+	// axis around which the shape turns, through Pivot; its length doesn't matter
+	// only needed if some keyframes have non-zero angles
+	Axis  Vector
+	Pivot Vector
+	// should be sorted by time, as done by MakeMoving
+	Keyframes []Keyframe
+}
+
+func MakeMoving(shape Shape, axis, pivot Vector, keyframes ...Keyframe) Moving {
+	sorted := append([]Keyframe(nil), keyframes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time < sorted[j].Time
+	})
+	return Moving{Shape: shape, Axis: axis, Pivot: pivot, Keyframes: sorted}
+}
+
+// shape moving in a straight line, by offset between times start and end, without turning
+func MakeLinearMotion(shape Shape, start, end float64, offset Vector) Moving {
+	return MakeMoving(shape, Zero(), Zero(), Keyframe{Time: start}, Keyframe{Time: end, Offset: offset})
+}
+
+// get the shape's offset and angle at time t
+func (m Moving) at(t float64) (Vector, float64) {
+	frames := m.Keyframes
+	if len(frames) == 0 {
+		return Zero(), 0
+	}
+	if t <= frames[0].Time {
+		return frames[0].Offset, frames[0].Angle
+	}
+	for i := 1; i < len(frames); i++ {
+		if t < frames[i].Time {
+			a, b := frames[i-1], frames[i]
+			w := (t - a.Time) / (b.Time - a.Time)
+			return a.Offset.MulScalar(1 - w).Add(b.Offset.MulScalar(w)), a.Angle*(1-w) + b.Angle*w
+		}
+	}
+	last := frames[len(frames)-1]
+	return last.Offset, last.Angle
+}
+
+// rotate v by angle around the Moving's axis, using Rodrigues' formula
+func (m Moving) rotate(v Vector, angle float64) Vector {
+	if angle == 0 || m.Axis == Zero() {
+		return v
+	}
+	k := m.Axis.Unit().Vector
+	sin, cos := math.Sincos(angle)
+	return v.MulScalar(cos).Add(k.Cross(v).MulScalar(sin)).Add(k.MulScalar(k.Dot(v) * (1 - cos)))
+}
+
+// intersect the ray with the shape where it is at the ray's time
+func (m Moving) Intersection(r Ray) SurfaceHit {
+	offset, angle := m.at(r.Time)
+	// move the ray into the shape's own frame, rather than moving the shape
+	local := r
+	local.Origin = m.rotate(r.Origin.Sub(offset).Sub(m.Pivot), -angle).Add(m.Pivot)
+	local.Direction = unitVector{m.rotate(r.Direction.Vector, -angle)}
+	hit := m.Shape.Intersection(local)
+	if !hit.Ok {
+		return hit
+	}
+	out := makeSurfaceHit(r, hit.Distance, unitVector{m.rotate(hit.Normal.Vector, angle)})
+	out.TexCoord = hit.TexCoord
+	return out
+}
+
+// bounds covering everywhere the shape goes, or false if the shape isn't Bounded
+func (m Moving) bounds() (AABB, bool) {
+	shape, ok := m.Shape.(Bounded)
+	if !ok {
+		return AABB{}, false
+	}
+	b := shape.Bounds()
+	turns := false
+	for _, frame := range m.Keyframes {
+		turns = turns || (frame.Angle != 0 && m.Axis != Zero())
+	}
+	if turns {
+		// the shape stays within the sphere around the pivot that contains its bounds
+		radius := 0.
+		for _, corner := range []Vector{
+			b.Min, {b.Min.X, b.Min.Y, b.Max.Z}, {b.Min.X, b.Max.Y, b.Min.Z}, {b.Min.X, b.Max.Y, b.Max.Z},
+			{b.Max.X, b.Min.Y, b.Min.Z}, {b.Max.X, b.Min.Y, b.Max.Z}, {b.Max.X, b.Max.Y, b.Min.Z}, b.Max,
+		} {
+			d := corner.Sub(m.Pivot)
+			radius = math.Max(radius, math.Sqrt(d.Dot(d)))
+		}
+		r := Vector{radius, radius, radius}
+		b = AABB{m.Pivot.Sub(r), m.Pivot.Add(r)}
+	}
+	if len(m.Keyframes) == 0 {
+		return b, true
+	}
+	// the shape moves in straight lines between keyframes, so the bounds at the keyframes cover everything in between
+	out := EmptyAABB()
+	for _, frame := range m.Keyframes {
+		out = out.Union(AABB{b.Min.Add(frame.Offset), b.Max.Add(frame.Offset)})
+	}
+	return out, true
+}
+
+// pick the time at which a ray is traced, uniformly within the shutter interval
+func (c *Camera) shutterTime(rng *RNG) float64 {
+	if c.ShutterClose <= c.ShutterOpen {
+		return c.ShutterOpen
+	}
+	return c.ShutterOpen + rng.Float64()*(c.ShutterClose-c.ShutterOpen)
+}
@@ -0,0 +1,379 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// brightness of the ambient light assumed by .mtl files, by which their Ka is scaled to give a material's Ambient
+// materials here glow with their ambient color, so white Ka would otherwise make a surface light itself at full brightness
+const OBJ_AMBIENT_LIGHT float64 = 0.1
+
+// material used for faces that don't reference any material
+var DEFAULT_OBJ_MATERIAL = ClassicMaterial{Ambient: OBJ_AMBIENT_LIGHT, Diffuse: 0.8, Specular: 0, Color: White()}
+
+// load geometry from a Wavefront .obj file, along with the materials in any .mtl files it references
+// faces are grouped into one mesh per object and material
+func LoadOBJ(path string) ([]Object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir := filepath.Dir(path)
+	return ReadOBJ(f, path, func(name string) (map[string]ClassicMaterial, error) {
+		return LoadMTL(filepath.Join(dir, name))
+	})
+}
+
+// load materials from a Wavefront .mtl file
+func LoadMTL(path string) (map[string]ClassicMaterial, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMTL(f, path)
+}
+
+// a group of faces sharing an object name and material
+type objGroup struct {
+	object, material string
+	faces            []Face
+}
+
+// parse .obj data from r; name is used in error messages
+// loadMaterials is called for each mtllib statement, and may be nil to ignore materials
+func ReadOBJ(r io.Reader, name string, loadMaterials func(string) (map[string]ClassicMaterial, error)) ([]Object, error) {
+	var vertices, normals []Vector
+	var texCoords []TexCoord
+	materials := map[string]ClassicMaterial{}
+	var groups []*objGroup
+	groupIndex := map[[2]string]*objGroup{}
+	object, material := "", ""
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("%s:%d: %s", name, lineNumber, fmt.Sprintf(format, args...))
+		}
+		args := fields[1:]
+		switch fields[0] {
+		case "v":
+			v, err := parseVector(args)
+			if err != nil {
+				return nil, fail("invalid vertex: %v", err)
+			}
+			vertices = append(vertices, v)
+		case "vn":
+			v, err := parseVector(args)
+			if err != nil {
+				return nil, fail("invalid normal: %v", err)
+			}
+			normals = append(normals, v)
+		case "vt":
+			if len(args) < 1 {
+				return nil, fail("texture coordinate needs at least one value")
+			}
+			var uv [2]float64
+			for i := 0; i < len(args) && i < 2; i++ {
+				f, err := strconv.ParseFloat(args[i], 64)
+				if err != nil {
+					return nil, fail("invalid texture coordinate: %v", err)
+				}
+				uv[i] = f
+			}
+			texCoords = append(texCoords, TexCoord{uv[0], uv[1]})
+		case "f":
+			if len(args) < 3 {
+				return nil, fail("face needs at least 3 vertices, got %d", len(args))
+			}
+			corners := make([][3]int, len(args))
+			for i, arg := range args {
+				corner, err := parseFaceCorner(arg, len(vertices), len(texCoords), len(normals))
+				if err != nil {
+					return nil, fail("invalid face vertex %q: %v", arg, err)
+				}
+				corners[i] = corner
+			}
+			key := [2]string{object, material}
+			group, ok := groupIndex[key]
+			if !ok {
+				group = &objGroup{object: object, material: material}
+				groupIndex[key] = group
+				groups = append(groups, group)
+			}
+			// triangulate polygons as a fan around the first vertex
+			for i := 1; i+1 < len(corners); i++ {
+				var face Face
+				for j, c := range [3][3]int{corners[0], corners[i], corners[i+1]} {
+					face.Vertices[j], face.TexCoords[j], face.Normals[j] = c[0], c[1], c[2]
+				}
+				group.faces = append(group.faces, face)
+			}
+		case "o", "g":
+			object = strings.Join(args, " ")
+		case "usemtl":
+			material = strings.Join(args, " ")
+		case "mtllib":
+			if loadMaterials == nil {
+				continue
+			}
+			for _, lib := range args {
+				loaded, err := loadMaterials(lib)
+				if err != nil {
+					return nil, fail("loading material library %q: %v", lib, err)
+				}
+				for k, v := range loaded {
+					materials[k] = v
+				}
+			}
+		default:
+			// ignore unsupported statements, e.g. smoothing groups and curves
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	objects := make([]Object, 0, len(groups))
+	for _, group := range groups {
+		material, ok := materials[group.material]
+		if !ok {
+			material = DEFAULT_OBJ_MATERIAL
+		}
+		mesh := compactMesh(vertices, normals, texCoords, group.faces)
+		objects = append(objects, Object{Shape: mesh, Material: material})
+	}
+	return objects, nil
+}
+
+// properties of a material in a .mtl file
+type mtlMaterial struct {
+	ka, kd, ks                        Vector
+	ns, transparency, refractiveIndex float64
+	illum                             int
+}
+
+func defaultMTLMaterial() mtlMaterial {
+	// defaults from the .mtl specification, except that surfaces have no highlights unless Ks is given
+	return mtlMaterial{
+		ka:              Vector{0.2, 0.2, 0.2},
+		kd:              Vector{0.8, 0.8, 0.8},
+		ks:              Zero(),
+		ns:              10,
+		refractiveIndex: 1,
+		illum:           2,
+	}
+}
+
+// parse .mtl data from r; name is used in error messages
+func ReadMTL(r io.Reader, name string) (map[string]ClassicMaterial, error) {
+	materials := map[string]ClassicMaterial{}
+	var current string
+	var m mtlMaterial
+	haveMaterial := false
+	finish := func() {
+		if haveMaterial {
+			materials[current] = m.material()
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		args := fields[1:]
+		var err error
+		switch fields[0] {
+		case "newmtl":
+			finish()
+			current = strings.Join(args, " ")
+			haveMaterial = true
+			m = defaultMTLMaterial()
+		case "Ka":
+			m.ka, err = parseMTLColor(args)
+		case "Kd":
+			m.kd, err = parseMTLColor(args)
+		case "Ks":
+			m.ks, err = parseMTLColor(args)
+		case "Ns":
+			m.ns, err = parseMTLScalar(args)
+		case "d":
+			// dissolve, i.e. opacity
+			var d float64
+			d, err = parseMTLScalar(args)
+			m.transparency = 1 - d
+		case "Tr":
+			m.transparency, err = parseMTLScalar(args)
+		case "Ni":
+			m.refractiveIndex, err = parseMTLScalar(args)
+		case "illum":
+			var illum float64
+			illum, err = parseMTLScalar(args)
+			m.illum = int(illum)
+		default:
+			// ignore unsupported statements, e.g. texture maps
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid %s: %v", name, lineNumber, fields[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	finish()
+	return materials, nil
+}
+
+// convert .mtl properties into a material
+// the diffuse color becomes the surface color, scaled so that its brightest channel is 1
+// Ks sets the strength of highlights, and also of mirror reflections for illumination models that enable them
+// Ka sets the ambient term, in the ambient light given by OBJ_AMBIENT_LIGHT; its hue is ignored, since ambient light takes the diffuse color
+func (m mtlMaterial) material() ClassicMaterial {
+	brightest := math.Max(m.kd.X, math.Max(m.kd.Y, m.kd.Z))
+	color := White()
+	if brightest > 0 {
+		color = m.kd.MulScalar(1 / brightest)
+	}
+	specular := 0.
+	if m.illum >= 3 && m.illum <= 7 {
+		specular = math.Min(mean(m.ks), 1)
+	}
+	return ClassicMaterial{
+		Ambient:         OBJ_AMBIENT_LIGHT * math.Min(mean(m.ka), 1),
+		Diffuse:         math.Min(brightest, 1),
+		Specular:        specular,
+		Color:           color,
+		Highlight:       math.Min(mean(m.ks), 1),
+		Shininess:       m.ns,
+		Transparency:    math.Min(math.Max(m.transparency, 0), 1),
+		RefractiveIndex: m.refractiveIndex,
+	}
+}
+
+func mean(v Vector) float64 {
+	return (v.X + v.Y + v.Z) / 3
+}
+
+func parseVector(args []string) (Vector, error) {
+	if len(args) < 3 {
+		return Vector{}, fmt.Errorf("expected 3 coordinates, got %d", len(args))
+	}
+	var coords [3]float64
+	for i := range coords {
+		f, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return Vector{}, err
+		}
+		coords[i] = f
+	}
+	return Vector{coords[0], coords[1], coords[2]}, nil
+}
+
+func parseMTLScalar(args []string) (float64, error) {
+	if len(args) < 1 {
+		return 0, fmt.Errorf("missing value")
+	}
+	// skip options such as "-halo"
+	return strconv.ParseFloat(args[len(args)-1], 64)
+}
+
+// colors may be given as a single value, which is used for all channels
+func parseMTLColor(args []string) (Vector, error) {
+	if len(args) > 0 && (args[0] == "spectral" || args[0] == "xyz") {
+		return Vector{}, fmt.Errorf("%s colors are not supported", args[0])
+	}
+	if len(args) == 1 || len(args) == 2 {
+		f, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return Vector{}, err
+		}
+		return Vector{f, f, f}, nil
+	}
+	return parseVector(args)
+}
+
+// parse a face corner of the form v, v/vt, v//vn or v/vt/vn into zero-based indices
+// missing texture coordinate and normal indices are set to -1
+func parseFaceCorner(s string, nVertices, nTexCoords, nNormals int) ([3]int, error) {
+	corner := [3]int{-1, -1, -1}
+	parts := strings.Split(s, "/")
+	if len(parts) > 3 {
+		return corner, fmt.Errorf("too many components")
+	}
+	counts := [3]int{nVertices, nTexCoords, nNormals}
+	for i, part := range parts {
+		if part == "" {
+			if i == 0 {
+				return corner, fmt.Errorf("missing vertex index")
+			}
+			continue
+		}
+		index, err := strconv.Atoi(part)
+		if err != nil {
+			return corner, err
+		}
+		// indices are 1-based, and negative indices count back from the most recent element
+		if index < 0 {
+			index = counts[i] + index
+		} else {
+			index--
+		}
+		if index < 0 || index >= counts[i] {
+			return corner, fmt.Errorf("index %s out of range", part)
+		}
+		corner[i] = index
+	}
+	return corner, nil
+}
+
+// build a mesh containing only the vertices, normals and texture coordinates used by the given faces
+func compactMesh(vertices, normals []Vector, texCoords []TexCoord, faces []Face) Mesh {
+	var outVertices, outNormals []Vector
+	var outTexCoords []TexCoord
+	vertexMap, normalMap, texCoordMap := map[int]int{}, map[int]int{}, map[int]int{}
+	remap := func(index int, m map[int]int, add func(int)) int {
+		if index < 0 {
+			return -1
+		}
+		if mapped, ok := m[index]; ok {
+			return mapped
+		}
+		mapped := len(m)
+		m[index] = mapped
+		add(index)
+		return mapped
+	}
+	outFaces := make([]Face, len(faces))
+	for i, face := range faces {
+		for j := 0; j < 3; j++ {
+			outFaces[i].Vertices[j] = remap(face.Vertices[j], vertexMap, func(k int) {
+				outVertices = append(outVertices, vertices[k])
+			})
+			outFaces[i].Normals[j] = remap(face.Normals[j], normalMap, func(k int) {
+				outNormals = append(outNormals, normals[k])
+			})
+			outFaces[i].TexCoords[j] = remap(face.TexCoords[j], texCoordMap, func(k int) {
+				outTexCoords = append(outTexCoords, texCoords[k])
+			})
+		}
+	}
+	return MakeMesh(outVertices, outNormals, outTexCoords, outFaces)
+}
@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+// exporters often write white Ka, which shouldn't make a material light itself
+func TestReadMTLWhiteAmbient(t *testing.T) {
+	mtl := "newmtl white\nKa 1 1 1\nKd 0.8 0.4 0.2\n"
+	materials, err := ReadMTL(strings.NewReader(mtl), "test.mtl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := materials["white"]
+	if !ok {
+		t.Fatalf("material not loaded: %v", materials)
+	}
+	if m.Ambient != DEFAULT_OBJ_MATERIAL.Ambient {
+		t.Errorf("ambient is %v, want %v", m.Ambient, DEFAULT_OBJ_MATERIAL.Ambient)
+	}
+	if want := (Vector{1, 0.5, 0.25}); m.Color != want {
+		t.Errorf("color is %v, want %v", m.Color, want)
+	}
+}
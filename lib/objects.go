@@ -6,23 +6,43 @@ import (
 
 const PLANE_TOL float64 = 0.001
 
-type Surface struct {
-	// all in range [0, 1]
-	Ambient, Diffuse, Specular float64
-	Color                      Vector
+// min distance to an intersection with a sphere, relative to its radius
+// prevents rays leaving a sphere's surface from intersecting it again at their origin
+const SPHERE_TOL float64 = 1e-6
+
+// where a ray intersects the surface of a shape
+type SurfaceHit struct {
+	// whether the ray hits the shape at all; if false, the other fields are meaningless
+	Ok bool
+	// distance along the ray to the intersection
+	Distance float64
+	// location of the intersection
+	Point Vector
+	// normal vector of the surface at the intersection
+	Normal unitVector
+	// texture coordinates at the intersection, for shapes that have them, such as triangles and meshes
+	TexCoord TexCoord
+}
+
+// the result of a ray missing a shape
+func Miss() SurfaceHit {
+	return SurfaceHit{}
+}
+
+// make a hit record for the intersection at the given distance along the ray
+func makeSurfaceHit(r Ray, dist float64, normal unitVector) SurfaceHit {
+	return SurfaceHit{Ok: true, Distance: dist, Point: r.Direction.MulScalar(dist).Add(r.Origin), Normal: normal}
 }
 
 type Shape interface {
-	// returns location of intersection between object and ray
-	// if no intersection, returns nil
-	Intersection(Ray) *Vector
-	// returns normal vector at given point
-	Normal(Vector) unitVector
+	// returns the nearest intersection between the shape and the ray
+	// if no intersection, returns a hit with Ok set to false
+	Intersection(Ray) SurfaceHit
 }
 
 type Object struct {
 	Shape
-	Surface
+	Material
 }
 
 type Sphere struct {
@@ -30,34 +50,36 @@ type Sphere struct {
 	Radius float64
 }
 
-func (s Sphere) Intersection(r Ray) *Vector {
-	// check that this sphere is not the ray source
-	if s.Center == r.Origin {
-		return nil
-	}
+func (s Sphere) Intersection(r Ray) SurfaceHit {
 	rayOriginToCenter := s.Center.Sub(r.Origin)
 	scalarProd := rayOriginToCenter.Dot(r.Direction.Vector)
-	if scalarProd < 0 {
-		// ray is pointing away from sphere
-		return nil
-	}
 	// rsq is squared distance between sphere center and projection of rayOriginToCenter onto ray
 	rsq := rayOriginToCenter.Dot(rayOriginToCenter) - scalarProd*scalarProd
 	sRadSq := s.Radius * s.Radius
 	if rsq > sRadSq {
 		// ray misses sphere
-		return nil
+		return Miss()
 	}
-	// ray hits sphere
-	// find distance from ray origin to intersection
+	// ray's line hits sphere
+	// find distance from ray origin to intersection, either entering the sphere or, if the ray starts inside it, exiting
 	lengthInSphere := math.Sqrt(sRadSq - rsq)
-	originToIntersection := r.Direction.MulScalar((scalarProd - lengthInSphere))
-	intersection := originToIntersection.Add(r.Origin)
-	return &intersection
+	tol := SPHERE_TOL * math.Max(s.Radius, 1)
+	dist := scalarProd - lengthInSphere
+	if dist < tol {
+		dist = scalarProd + lengthInSphere
+	}
+	if dist < tol {
+		// sphere is behind the ray
+		return Miss()
+	}
+	hit := makeSurfaceHit(r, dist, unitVector{})
+	hit.Normal = hit.Point.Sub(s.Center).Unit()
+	return hit
 }
 
-func (s Sphere) Normal(p Vector) unitVector {
-	return p.Sub(s.Center).Unit()
+func (s Sphere) Bounds() AABB {
+	r := Vector{s.Radius, s.Radius, s.Radius}
+	return AABB{s.Center.Sub(r), s.Center.Add(r)}
 }
 
 // a single-sided plane
@@ -66,17 +88,11 @@ type Plane struct {
 	Norm  unitVector // normal vector facing away from viewable side of plane
 }
 
-func (p Plane) Intersection(r Ray) *Vector {
+func (p Plane) Intersection(r Ray) SurfaceHit {
 	dist := p.Point.Sub(r.Origin).Dot(p.Norm.Vector) / r.Direction.Dot(p.Norm.Vector)
 	if dist < PLANE_TOL {
 		// ray is not pointing toward the plane
-		return nil
+		return Miss()
 	}
-	intersection := r.Direction.MulScalar(dist).Add(r.Origin)
-	// fmt.Println(dist, intersection)
-	return &intersection
-}
-
-func (p Plane) Normal(v Vector) unitVector {
-	return p.Norm
+	return makeSurfaceHit(r, dist, p.Norm)
 }
@@ -0,0 +1,103 @@
+package lib
+
+import "math"
+
+// maps points on a camera's image to the rays that are traced through them
+type Projection interface {
+	// get the ray through the given point, in pixel coordinates, or false if no ray passes through it
+	// rng can be used for random choices such as picking a point on a lens
+	Ray(c *Camera, x, y float64, rng *RNG) (Ray, bool)
+}
+
+// the projection used by cameras that don't set one
+func DefaultProjection() Projection {
+	return Perspective{}
+}
+
+// position of a point on the image plane, relative to its center, with the same scale as HalfWidth and HalfHeight
+func (c *Camera) imagePlane(x, y float64) (float64, float64) {
+	return x*c.PixelWidth - c.HalfWidth, y*c.PixelHeight - c.HalfHeight
+}
+
+// a pinhole or thin-lens camera, where rays spread out from the camera's position through an image plane at distance 1
+// the camera's HalfWidth and HalfHeight give the size of the image plane, and its aperture settings give depth of field
+type Perspective struct{}
+
+func (Perspective) Ray(c *Camera, x, y float64, rng *RNG) (Ray, bool) {
+	// create ray looking at point
+	u, v := c.imagePlane(x, y)
+	toImagePlane := c.LookAt.Add(c.Right.MulScalar(u)).Add(c.Up.MulScalar(v))
+	ray := Ray{Origin: c.Position, Direction: toImagePlane.Unit()}
+	if c.Aperture > 0 {
+		// rays through every point on the lens converge at the focal plane
+		focus := c.FocusDistance
+		if focus <= 0 {
+			focus = 1
+		}
+		focalPoint := c.Position.Add(toImagePlane.MulScalar(focus))
+		lx, ly := c.lensOffset(rng)
+		ray.Origin = c.Position.Add(c.Right.MulScalar(lx)).Add(c.Up.MulScalar(ly))
+		ray.Direction = focalPoint.Sub(ray.Origin).Unit()
+	}
+	return ray, true
+}
+
+// parallel rays along the camera's view direction, starting from a rectangle centered on its position
+// objects keep the same size regardless of their distance, as in technical drawings
+type Orthographic struct {
+	// width of the view, in scene units; the height follows from the image's aspect ratio
+	// if 0, the view is as wide as the perspective camera's image plane, 2 * HalfWidth
+	Width float64
+}
+
+func (p Orthographic) Ray(c *Camera, x, y float64, rng *RNG) (Ray, bool) {
+	u, v := c.imagePlane(x, y)
+	if p.Width > 0 {
+		scale := p.Width / (2 * c.HalfWidth)
+		u, v = u*scale, v*scale
+	}
+	origin := c.Position.Add(c.Right.MulScalar(u)).Add(c.Up.MulScalar(v))
+	return Ray{Origin: origin, Direction: c.LookAt}, true
+}
+
+// equidistant fisheye, which maps the angle from the view direction linearly to distance from the image's center
+// the view fills a circle touching the shorter sides of the image; pixels outside it are black
+type Fisheye struct {
+	// angle of view across the circle, in radians, up to 2 pi; if 0, uses pi (a hemisphere)
+	FOV float64
+}
+
+func (p Fisheye) Ray(c *Camera, x, y float64, rng *RNG) (Ray, bool) {
+	u, v := c.imagePlane(x, y)
+	radius := math.Min(c.HalfWidth, c.HalfHeight)
+	u, v = u/radius, v/radius
+	r := math.Sqrt(u*u + v*v)
+	if r > 1 {
+		return Ray{}, false
+	}
+	fov := p.FOV
+	if fov <= 0 {
+		fov = math.Pi
+	}
+	theta := r * fov / 2
+	direction := c.LookAt.MulScalar(math.Cos(theta))
+	if r > 0 {
+		sideways := c.Right.MulScalar(u / r).Add(c.Up.MulScalar(v / r))
+		direction = direction.Add(sideways.MulScalar(math.Sin(theta)))
+	}
+	return Ray{Origin: c.Position, Direction: direction.Unit()}, true
+}
+
+// full-sphere panorama, with longitude across the image and latitude down it, as used by 360 degree viewers
+// the view direction is at the center of the image; images should be twice as wide as they are high
+type Equirectangular struct{}
+
+func (Equirectangular) Ray(c *Camera, x, y float64, rng *RNG) (Ray, bool) {
+	// pixel centers are spaced evenly, so that the left and right edges of the image meet seamlessly
+	longitude := ((x+0.5)/float64(c.Width) - 0.5) * 2 * math.Pi
+	latitude := (0.5 - (y+0.5)/float64(c.Height)) * math.Pi
+	// the camera's Up points toward the bottom of the image
+	horizontal := c.LookAt.MulScalar(math.Cos(longitude)).Add(c.Right.MulScalar(math.Sin(longitude)))
+	direction := horizontal.MulScalar(math.Cos(latitude)).Sub(c.Up.MulScalar(math.Sin(latitude)))
+	return Ray{Origin: c.Position, Direction: direction.Unit()}, true
+}
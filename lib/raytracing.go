@@ -1,11 +1,11 @@
 package lib
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
-	"runtime"
 	"time"
 )
 
@@ -18,8 +18,25 @@ type Camera struct {
 	Position                                       Vector
 	LookAt, Up, Right                              unitVector
 	HalfWidth, HalfHeight, PixelWidth, PixelHeight float64
+	// radius of the lens; if 0, the camera is a pinhole and everything is in focus
+	Aperture float64
+	// distance along LookAt to the plane that is in perfect focus; if 0, uses 1
+	FocusDistance float64
+	// number of straight edges of the aperture, which shape out-of-focus highlights; if less than 3, the aperture is round
+	ApertureBlades int
+	// rotation of a polygonal aperture, in radians
+	ApertureRotation float64
+	// how points on the image map to rays; if nil, uses DefaultProjection()
+	Projection Projection
+	// times at which the shutter opens and closes; each ray is traced at a random time between them,
+	// so that Moving shapes are blurred along their paths
+	// if ShutterClose isn't after ShutterOpen, every ray is traced at ShutterOpen
+	ShutterOpen, ShutterClose float64
 }
 
+// make a camera from its basis vectors, which should be orthonormal; Up should point toward the bottom of the image
+// FieldOfView is half of the horizontal angle of view, in radians
+// MakeLookAtCamera is usually more convenient, and checks its inputs
 func MakeCamera(
 	Width, Height int,
 	Position Vector,
@@ -32,13 +49,20 @@ func MakeCamera(
 	pixelWidth := 2 * halfWidth / float64(Width-1)
 	pixelHeight := 2 * halfHeight / float64(Height-1)
 	return Camera{
-		Width, Height,
-		Position,
-		LookAt, Up, Right,
-		halfWidth, halfHeight, pixelWidth, pixelHeight,
+		Width:       Width,
+		Height:      Height,
+		Position:    Position,
+		LookAt:      LookAt,
+		Up:          Up,
+		Right:       Right,
+		HalfWidth:   halfWidth,
+		HalfHeight:  halfHeight,
+		PixelWidth:  pixelWidth,
+		PixelHeight: pixelHeight,
 	}
 }
 
+// camera at the origin looking along the z axis, with the y axis pointing down the image and a 90 degree horizontal field of view
 func DefaultCamera(width, height int) Camera {
 	return MakeCamera(
 		width, height,
@@ -48,157 +72,218 @@ func DefaultCamera(width, height int) Camera {
 	)
 }
 
+// same camera with a different image size and field of view, which is half of the horizontal angle of view as in MakeCamera
 func (c Camera) Resized(width, height int, fov float64) Camera {
-	return MakeCamera(
+	resized := MakeCamera(
 		width, height,
 		c.Position,
 		c.LookAt, c.Up, c.Right,
 		fov,
 	)
+	resized.Aperture, resized.FocusDistance = c.Aperture, c.FocusDistance
+	resized.ApertureBlades, resized.ApertureRotation = c.ApertureBlades, c.ApertureRotation
+	resized.Projection = c.Projection
+	resized.ShutterOpen, resized.ShutterClose = c.ShutterOpen, c.ShutterClose
+	return resized
 }
 
-type Light struct {
-	Position  Vector
-	Intensity float64
-	Threshold float64
-}
-
-func MakeLight(position Vector, intensity float64) Light {
-	threshold := -math.Log(HALO_THRESHOLD/intensity) / HALO_DROPOFF
-	return Light{position, intensity, threshold}
+// same camera with a different image size, keeping the horizontal field of view
+func (c Camera) WithSize(width, height int) Camera {
+	c.Width, c.Height = width, height
+	c.HalfHeight = float64(height) / float64(width) * c.HalfWidth
+	c.PixelWidth = 2 * c.HalfWidth / float64(width-1)
+	c.PixelHeight = 2 * c.HalfHeight / float64(height-1)
+	return c
 }
 
 type Scene struct {
 	Camera  Camera
 	Objects []Object
 	Lights  []Light
+	// built at the start of a render; if nil, intersections are found by testing every object
+	accel *sceneAccel
 }
 
 type Ray struct {
 	Origin    Vector
 	Direction unitVector
+	// moment at which the ray is traced, within the camera's shutter interval
+	Time float64
 }
 
 // find the first object that the ray intersects
-// returns the object and the location of intersection
-func (r Ray) firstIntersection(objects *[]Object) (*Object, *Vector) {
+// returns the object, or nil if there is none, and where the ray hits it
+func (s *Scene) firstIntersection(r Ray) (*Object, SurfaceHit) {
 	var fi *Object
-	var fiLoc *Vector
-	var fiSqDist float64
-	for i := range *objects {
-		object := (*objects)[i] // avoid copy so we can use address
-		loc := object.Intersection(r)
-		if loc == nil {
-			continue
+	var fiHit SurfaceHit
+	fiIndex := -1
+	// objects are compared by distance, then by index, so that the result doesn't depend on the order in which they're tested
+	test := func(i int) {
+		hit := s.Objects[i].Intersection(r)
+		if !hit.Ok {
+			return
 		}
-		delta := (*loc).Sub(r.Origin)
-		sqDist := delta.Dot(delta)
-		if fi == nil || sqDist < fiSqDist {
-			fi = &object
-			fiLoc = loc
-			fiSqDist = sqDist
+		if fi == nil || hit.Distance < fiHit.Distance || (hit.Distance == fiHit.Distance && i < fiIndex) {
+			fi = &s.Objects[i]
+			fiHit = hit
+			fiIndex = i
+		}
+	}
+	if s.accel == nil {
+		for i := range s.Objects {
+			test(i)
 		}
+		return fi, fiHit
 	}
-	return fi, fiLoc
+	for _, i := range s.accel.unbounded {
+		test(i)
+	}
+	s.accel.tree.walk(r, func(item int) float64 {
+		test(s.accel.bounded[item])
+		if fi == nil {
+			return math.Inf(1)
+		}
+		// allow some slack, so that ties aren't culled due to rounding
+		return fiHit.Distance*(1+BVH_PADDING) + BVH_PADDING
+	})
+	return fi, fiHit
 }
 
-func (s Scene) visibleLights(p Vector) []*Light {
-	var visible []*Light
-	for i := range s.Lights {
-		light := s.Lights[i] // avoid copy so we can use address
-		r := Ray{Origin: light.Position, Direction: p.Sub(light.Position).Unit()}
-		fi, _ := r.firstIntersection(&s.Objects)
-		if fi != nil {
-			visible = append(visible, &light)
+// check whether any object lies along the ray before it reaches maxDist
+func (s *Scene) occluded(r Ray, maxDist float64) bool {
+	blocks := func(i int) bool {
+		hit := s.Objects[i].Intersection(r)
+		return hit.Ok && hit.Distance < maxDist
+	}
+	if s.accel == nil {
+		for i := range s.Objects {
+			if blocks(i) {
+				return true
+			}
 		}
+		return false
 	}
-	return visible
+	for _, i := range s.accel.unbounded {
+		if blocks(i) {
+			return true
+		}
+	}
+	return s.accel.tree.any(r, maxDist, func(item int) bool {
+		return blocks(s.accel.bounded[item])
+	})
 }
 
-func (s Scene) checkForLight(r Ray) Vector {
+// get the light seen directly along a ray that misses every object, from the halos of lights that have them
+func (s *Scene) checkForLight(r Ray) Vector {
 	out := Zero()
 	for _, light := range s.Lights {
-		// get scalar projection of ray connecting light and camera onto ray
-		// if this is negative, the light is behind the camera
-		cameraToLight := light.Position.Sub(r.Origin)
-		scalarProjection := cameraToLight.Dot(r.Direction.Vector)
-		if scalarProjection < 0 {
-			continue
-		}
-		// get distance between projected ray and light; use this to calculate light intensity
-		distance := math.Sqrt(cameraToLight.Dot(cameraToLight) - scalarProjection*scalarProjection)
-		if distance > light.Threshold {
-			continue
+		if halo, ok := light.(HaloLight); ok {
+			out = out.Add(halo.Halo(r))
 		}
-		intensity := light.Intensity * math.Exp(-HALO_DROPOFF*distance)
-		out = out.Add(White().MulScalar(intensity))
 	}
 	return out
 }
 
-func (r Ray) interact(o *Object, loc *Vector, s *Scene, depth int) Vector {
-	normal := o.Normal(*loc)
-	color := o.Surface.Color.MulScalar(o.Surface.Ambient)
-	if o.Surface.Diffuse > 0 {
-		diffusion := 0.
-		for _, light := range s.visibleLights(*loc) {
-			contribution := light.Intensity * normal.Dot(light.Position.Sub(*loc).Unit().Vector)
-			if contribution > 0 {
-				diffusion += contribution
-			}
-		}
-		if diffusion > 1 {
-			diffusion = 1
-		}
-		diffuseColor := o.Surface.Color.MulScalar(diffusion * o.Surface.Diffuse)
-		color = color.Add(diffuseColor)
+func (r Ray) interact(o *Object, hit SurfaceHit, s *Scene, depth int, rng *RNG) Vector {
+	h := Hit{Ray: r, Point: hit.Point, Normal: hit.Normal, TexCoord: hit.TexCoord, scene: s, rng: rng}
+	color := o.Emission(h)
+	direct, rays := o.Scatter(h)
+	color = color.Add(direct)
+	for _, scattered := range rays {
+		// light is treated as travelling instantly, so every ray along a path shares the same time
+		ray := scattered.Ray
+		ray.Time = r.Time
+		color = color.Add(s.trace(ray, depth+1, rng).Mul(scattered.Weight))
 	}
-	if o.Surface.Specular > 0 {
-		reflection := r.Direction.Reflect(normal.Vector).Unit()
-		reflectionRay := Ray{Origin: *loc, Direction: reflection}
-		reflectedColor := s.trace(reflectionRay, depth+1)
-		specularColor := reflectedColor.MulScalar(o.Surface.Specular)
-		color = color.Add(specularColor)
-	}
-
-	return color
+	return color.Mul(o.Attenuation(h))
 }
 
-func (s Scene) trace(r Ray, depth int) Vector {
+// rng is used for random choices along the ray's path, such as points on area lights
+func (s *Scene) trace(r Ray, depth int, rng *RNG) Vector {
 	if depth > MAX_DEPTH {
 		return Zero()
 	}
-	fi, fiLoc := r.firstIntersection(&s.Objects)
+	fi, hit := s.firstIntersection(r)
 	if fi == nil {
 		return s.checkForLight(r)
 	}
-	return r.interact(fi, fiLoc, &s, depth)
+	return r.interact(fi, hit, s, depth, rng)
 }
 
-func (s Scene) RenderPixel(x int, y int) *color.RGBA {
-	// create ray looking at pixel
-	xComp := s.Camera.Right.MulScalar(float64(x)*s.Camera.PixelWidth - s.Camera.HalfWidth)
-	yComp := s.Camera.Up.MulScalar(float64(y)*s.Camera.PixelHeight - s.Camera.HalfHeight)
-	direction := s.Camera.LookAt.Add(xComp).Add(yComp).Unit()
-	ray := Ray{Origin: s.Camera.Position, Direction: direction}
-	// trace ray
-	traceResult := s.trace(ray, 0).Trim(0, 1)
-	pixel, error := traceResult.ToColor()
-	if pixel == nil {
-		fmt.Printf("error when unpacking color: %s\n", error)
-		pixel = &color.RGBA{0, 0, 0, 255}
+// trace the ray through the given point on the image, in pixel coordinates, as given by the camera's projection
+// rng is passed to the projection, e.g. for picking a point on the lens, and used to pick the ray's time and sample area lights
+func (s *Scene) castRay(x, y float64, rng *RNG) Vector {
+	projection := s.Camera.Projection
+	if projection == nil {
+		projection = DefaultProjection()
+	}
+	ray, ok := projection.Ray(&s.Camera, x, y, rng)
+	if !ok {
+		return Zero()
 	}
-	return pixel
+	ray.Time = s.Camera.shutterTime(rng)
+	return s.trace(ray, 0, rng)
+}
+
+func (s Scene) RenderPixel(x int, y int) color.RGBA {
+	opts := RenderOptions{}.withDefaults()
+	return s.renderPixel(x, y, &opts)
+}
+
+// render a pixel using only its own samples, weighted by the options' filter
+// opts should have defaults filled in
+func (s *Scene) renderPixel(x, y int, opts *RenderOptions) color.RGBA {
+	f := makeFilm(image.Rect(x, y, x+1, y+1))
+	rngs := pixelRNGs{sampler: pixelRNG(opts.Seed, x, y)}
+	s.samplePixel(&f, x, y, opts, &rngs, 0)
+	c, _ := f.pixel(x, y)
+	return opts.rgba(c, x, y)
+}
+
+// trace rays through points covering a pixel, chosen by the options' sampler, and add them to f
+// first is the index in the sampler's pattern of the first sample to take, for continuing from earlier passes
+// opts should have defaults filled in; rngs.sampler should be the pixel's generator, e.g. from pixelRNG
+// returns the number of samples taken
+func (s *Scene) samplePixel(f *film, x, y int, opts *RenderOptions, rngs *pixelRNGs, first int) int {
+	adaptive := opts.MaxSamples > opts.Samples
+	var stats runningStats
+	sample := func(i int) {
+		dx, dy := opts.Sampler.Sample(first+i, opts.Samples, &rngs.sampler)
+		sx, sy := float64(x)+dx-0.5, float64(y)+dy-0.5
+		rngs.sample = pixelRNG(opts.Seed^uint64(first+i+1)*0x9e3779b97f4a7c15, x, y)
+		c := s.castRay(sx, sy, &rngs.sample)
+		if adaptive {
+			// noise is measured in the output image, since that's where it's visible
+			stats.add(luminance(opts.encode(c)))
+		}
+		f.splat(sx, sy, c, opts.Filter)
+	}
+	n := 0
+	for ; n < opts.Samples; n++ {
+		sample(n)
+	}
+	// keep taking rounds of samples until the pixel's estimated noise is low enough
+	for adaptive && n < opts.MaxSamples && stats.standardError() > opts.NoiseThreshold {
+		end := n + opts.Samples
+		if end > opts.MaxSamples {
+			end = opts.MaxSamples
+		}
+		for ; n < end; n++ {
+			sample(n)
+		}
+	}
+	return n
 }
 
 func (s Scene) Render() *image.RGBA {
+	s.accel = buildSceneAccel(s.Objects)
 	camera := s.Camera
 	img := image.NewRGBA(image.Rect(0, 0, camera.Width, camera.Height))
+	opts := RenderOptions{}.withDefaults()
 	timeStart := time.Now()
 	for x := 0; x < camera.Width; x++ {
 		for y := 0; y < camera.Height; y++ {
-			pixel := s.RenderPixel(x, y)
-			img.Set(x, y, pixel)
+			img.SetRGBA(x, y, s.renderPixel(x, y, &opts))
 		}
 	}
 	timeEnd := time.Now()
@@ -208,34 +293,15 @@ func (s Scene) Render() *image.RGBA {
 
 // same functionality as Render, but works in parallel, using all available CPU cores
 func (s Scene) ConcurrentRender() *image.RGBA {
-	img := image.NewRGBA(image.Rect(0, 0, s.Camera.Width, s.Camera.Height))
-	nCores := runtime.NumCPU()
 	timeStart := time.Now()
-	// render chunks in parallel
-	jobSize := s.Camera.Height * s.Camera.Width / nCores
-	jobs := make([]Job, 0, nCores)
-	for i := 0; i < nCores; i++ {
-		start := i * jobSize
-		end := start + jobSize
-		if i == nCores-1 {
-			end = s.Camera.Height * s.Camera.Width
-		}
-		jobs = append(jobs, Job{Scene: &s, Start: start, End: end})
-	}
-	c := make(chan JobResult, nCores)
-	for _, job := range jobs {
-		go job.Run(c)
-	}
-	for i := 0; i < nCores; i++ {
-		result := <-c
-		for j, p := range result.Pixels {
-			index := result.Start + j
-			x := index % s.Camera.Width
-			y := index / s.Camera.Width
-			img.Set(x, y, p)
-		}
-	}
+	img := s.RenderWithOptions(RenderOptions{})
 	timeEnd := time.Now()
 	fmt.Printf("Rendered in %v\n", timeEnd.Sub(timeStart))
 	return img
 }
+
+// render the scene in parallel, as configured by opts
+func (s Scene) RenderWithOptions(opts RenderOptions) *image.RGBA {
+	img, _ := s.RenderContext(context.Background(), opts)
+	return img
+}
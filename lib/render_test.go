@@ -0,0 +1,37 @@
+package lib
+
+import "testing"
+
+// the scene rendered by examples/reflective-spheres, at a smaller size
+func loadBenchmarkScene(b *testing.B) Scene {
+	s, err := LoadScene("../examples/reflective-spheres/scene.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	s.Camera = s.Camera.WithSize(320, 180)
+	return s
+}
+
+func BenchmarkRender(b *testing.B) {
+	s := loadBenchmarkScene(b)
+	// one worker, so that timings don't depend on the number of cores
+	opts := RenderOptions{Workers: 1}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.RenderWithOptions(opts)
+	}
+}
+
+// tracing a single ray, including its reflections and shadow rays
+func BenchmarkCastRay(b *testing.B) {
+	s := loadBenchmarkScene(b)
+	s.accel = buildSceneAccel(s.Objects)
+	rng := MakeRNG(0)
+	w, h := s.Camera.Width, s.Camera.Height
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.castRay(float64(i%w), float64(i/w%h), &rng)
+	}
+}
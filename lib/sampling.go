@@ -0,0 +1,128 @@
+package lib
+
+import "math"
+
+// small, fast pseudorandom number generator (splitmix64)
+// not safe for concurrent use; each pixel gets its own, so that renders are reproducible regardless of scheduling
+type RNG struct {
+	state uint64
+}
+
+func MakeRNG(seed uint64) RNG {
+	return RNG{seed}
+}
+
+func (r *RNG) Uint64() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// returns a uniformly distributed number in [0, 1)
+func (r *RNG) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
+
+// get the generator for a pixel, derived from the render's seed and the pixel's coordinates
+func pixelRNG(seed uint64, x, y int) RNG {
+	mixer := MakeRNG(seed ^ uint64(uint32(x)) ^ uint64(uint32(y))<<32)
+	return MakeRNG(mixer.Uint64())
+}
+
+// random number generators used while rendering a pixel
+type pixelRNGs struct {
+	// used only by the pixel's sampler, and kept for all of its samples
+	sampler RNG
+	// reset for each sample, for random choices made while tracing it, so that they don't disturb the sampler
+	sample RNG
+}
+
+// chooses the points within a pixel through which rays are traced
+type Sampler interface {
+	// get the position of sample i of n within the pixel, with each coordinate in [0, 1)
+	// i may be n or more when adaptive sampling takes extra samples; the pattern should then continue with new points
+	// rng is unique to the pixel and shared by all of its samples, and isn't used for anything else
+	Sample(i, n int, rng *RNG) (float64, float64)
+}
+
+// size of the most nearly square grid with at least n cells
+func sampleGrid(n int) (int, int) {
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	rows := (n + cols - 1) / cols
+	return cols, rows
+}
+
+// samples at the centers of the cells of a regular grid
+// a single sample is placed at the center of the pixel
+// extra samples are placed on copies of the grid, each shifted to a different point within the cells
+type RegularSampler struct{}
+
+func (RegularSampler) Sample(i, n int, rng *RNG) (float64, float64) {
+	cols, rows := sampleGrid(n)
+	round, i := i/n, i%n
+	dx, dy := 0.5, 0.5
+	if round > 0 {
+		dx, dy = radicalInverse(round, 2), radicalInverse(round, 3)
+	}
+	return (float64(i%cols) + dx) / float64(cols), (float64(i/cols) + dy) / float64(rows)
+}
+
+// samples at random positions within the cells of a regular grid (stratified sampling)
+type JitteredSampler struct{}
+
+func (JitteredSampler) Sample(i, n int, rng *RNG) (float64, float64) {
+	cols, rows := sampleGrid(n)
+	i %= n
+	return (float64(i%cols) + rng.Float64()) / float64(cols), (float64(i/cols) + rng.Float64()) / float64(rows)
+}
+
+// samples from the low-discrepancy Halton sequence in bases 2 and 3
+// the sequence is shifted by a random offset for each pixel, so that neighboring pixels use different patterns
+type HaltonSampler struct{}
+
+func (HaltonSampler) Sample(i, n int, rng *RNG) (float64, float64) {
+	// draw the offset from a copy of rng, so that it's the same for every sample in the pixel
+	offset := *rng
+	x, y := radicalInverse(i+1, 2)+offset.Float64(), radicalInverse(i+1, 3)+offset.Float64()
+	return x - math.Floor(x), y - math.Floor(y)
+}
+
+// mirror the digits of i in the given base around the decimal point, e.g. 6 = 110b becomes 0.011b
+func radicalInverse(i, base int) float64 {
+	result, scale := 0., 1.
+	for i > 0 {
+		scale /= float64(base)
+		result += float64(i%base) * scale
+		i /= base
+	}
+	return result
+}
+
+// estimates the mean and variance of a stream of values, using Welford's algorithm
+type runningStats struct {
+	n        int
+	mean, m2 float64
+}
+
+func (r *runningStats) add(x float64) {
+	r.n++
+	delta := x - r.mean
+	r.mean += delta / float64(r.n)
+	r.m2 += delta * (x - r.mean)
+}
+
+// estimated standard deviation of the mean of the values added so far
+func (r *runningStats) standardError() float64 {
+	if r.n < 2 {
+		return math.Inf(1)
+	}
+	variance := r.m2 / float64(r.n-1)
+	return math.Sqrt(variance / float64(r.n))
+}
+
+// perceived brightness of a linear color
+func luminance(c Vector) float64 {
+	return 0.2126*c.X + 0.7152*c.Y + 0.0722*c.Z
+}
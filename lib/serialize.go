@@ -0,0 +1,435 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// name of the JSON field holding the registered name of a shape's, material's, projection's or light's type
+const TYPE_FIELD string = "Type"
+
+// maps the type names used in scene files to the Go types they represent
+type typeRegistry[T any] struct {
+	kind   string
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+func newTypeRegistry[T any](kind string) *typeRegistry[T] {
+	return &typeRegistry[T]{
+		kind:   kind,
+		byName: map[string]reflect.Type{},
+		byType: map[reflect.Type]string{},
+	}
+}
+
+func (r *typeRegistry[T]) register(name string, example T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := reflect.TypeOf(example)
+	if t == nil {
+		panic(fmt.Sprintf("cannot register nil as %s type %q", r.kind, name))
+	}
+	if existing, ok := r.byName[name]; ok && existing != t {
+		panic(fmt.Sprintf("%s type name %q is already registered for %v", r.kind, name, existing))
+	}
+	r.byName[name] = t
+	r.byType[t] = name
+}
+
+// encode v as a JSON object, with its registered type name added under TYPE_FIELD
+func (r *typeRegistry[T]) marshal(v T) ([]byte, error) {
+	r.mu.RLock()
+	name, ok := r.byType[reflect.TypeOf(v)]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s type %T is not registered", r.kind, v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil, fmt.Errorf("%s type %T must be encoded as a JSON object", r.kind, v)
+	}
+	fields[TYPE_FIELD], _ = json.Marshal(name)
+	return json.Marshal(fields)
+}
+
+// decode a JSON object written by marshal
+func (r *typeRegistry[T]) unmarshal(data []byte) (T, error) {
+	var zero T
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return zero, fmt.Errorf("%s must be a JSON object", r.kind)
+	}
+	rawName, ok := fields[TYPE_FIELD]
+	if !ok {
+		return zero, fmt.Errorf("%s is missing the %q field", r.kind, TYPE_FIELD)
+	}
+	var name string
+	if err := json.Unmarshal(rawName, &name); err != nil {
+		return zero, fmt.Errorf("%s %q field must be a string", r.kind, TYPE_FIELD)
+	}
+	r.mu.RLock()
+	t, ok := r.byName[name]
+	r.mu.RUnlock()
+	if !ok {
+		return zero, fmt.Errorf("unknown %s type %q", r.kind, name)
+	}
+	delete(fields, TYPE_FIELD)
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return zero, err
+	}
+	ptr := reflect.New(t)
+	if err := decodeStrict(rest, ptr.Interface()); err != nil {
+		return zero, fmt.Errorf("invalid %s of type %q: %v", r.kind, name, err)
+	}
+	return ptr.Elem().Interface().(T), nil
+}
+
+// decode JSON, rejecting fields that don't exist in v, to catch typos in hand-written scene files
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+var shapeTypes = newTypeRegistry[Shape]("shape")
+var materialTypes = newTypeRegistry[Material]("material")
+var projectionTypes = newTypeRegistry[Projection]("projection")
+var lightTypes = newTypeRegistry[Light]("light")
+
+// make a shape type available for saving and loading scenes, under the given name
+// example can be any value of the type, e.g. Sphere{}; the type must be encoded as a JSON object
+func RegisterShape(name string, example Shape) {
+	shapeTypes.register(name, example)
+}
+
+// make a material type available for saving and loading scenes, under the given name
+// example can be any value of the type, e.g. ClassicMaterial{}; the type must be encoded as a JSON object
+func RegisterMaterial(name string, example Material) {
+	materialTypes.register(name, example)
+}
+
+// make a camera projection type available for saving and loading scenes, under the given name
+// example can be any value of the type, e.g. Perspective{}; the type must be encoded as a JSON object
+func RegisterProjection(name string, example Projection) {
+	projectionTypes.register(name, example)
+}
+
+// make a light type available for saving and loading scenes, under the given name
+// example can be any value of the type, e.g. PointLight{}; the type must be encoded as a JSON object
+func RegisterLight(name string, example Light) {
+	lightTypes.register(name, example)
+}
+
+func init() {
+	RegisterShape("sphere", Sphere{})
+	RegisterShape("plane", Plane{})
+	RegisterShape("triangle", Triangle{})
+	RegisterShape("mesh", Mesh{})
+	RegisterShape("moving", Moving{})
+	RegisterMaterial("classic", ClassicMaterial{})
+	RegisterProjection("perspective", Perspective{})
+	RegisterProjection("orthographic", Orthographic{})
+	RegisterProjection("fisheye", Fisheye{})
+	RegisterProjection("equirectangular", Equirectangular{})
+	RegisterLight("point", PointLight{})
+	RegisterLight("spot", SpotLight{})
+	RegisterLight("directional", DirectionalLight{})
+	RegisterLight("rect", RectLight{})
+	RegisterLight("disk", DiskLight{})
+	RegisterLight("sphere", SphereLight{})
+}
+
+// alias type doesn't have the MarshalJSON and UnmarshalJSON methods, so encoding it won't recurse
+type plainCamera Camera
+
+// the projection field shadows the one in plainCamera, so that it can be encoded with its type
+type cameraJSON struct {
+	plainCamera
+	Projection json.RawMessage `json:",omitempty"`
+}
+
+func (c Camera) MarshalJSON() ([]byte, error) {
+	raw := cameraJSON{plainCamera: plainCamera(c)}
+	if c.Projection != nil {
+		projection, err := projectionTypes.marshal(c.Projection)
+		if err != nil {
+			return nil, err
+		}
+		raw.Projection = projection
+	}
+	return json.Marshal(raw)
+}
+
+func (c *Camera) UnmarshalJSON(data []byte) error {
+	var raw cameraJSON
+	if err := decodeStrict(data, &raw); err != nil {
+		return err
+	}
+	camera := Camera(raw.plainCamera)
+	if raw.Projection != nil && string(raw.Projection) != "null" {
+		projection, err := projectionTypes.unmarshal(raw.Projection)
+		if err != nil {
+			return fmt.Errorf("invalid camera: %v", err)
+		}
+		camera.Projection = projection
+	}
+	if camera.Width < 2 || camera.Height < 2 {
+		return fmt.Errorf("invalid camera: image must be at least 2x2 pixels, got %dx%d", camera.Width, camera.Height)
+	}
+	if !(camera.HalfWidth > 0) || math.IsInf(camera.HalfWidth, 1) {
+		return fmt.Errorf("invalid camera: HalfWidth must be positive, got %v", camera.HalfWidth)
+	}
+	for _, axis := range []struct {
+		name string
+		v    *unitVector
+	}{{"LookAt", &camera.LookAt}, {"Up", &camera.Up}, {"Right", &camera.Right}} {
+		lengthSq := axis.v.Dot(axis.v.Vector)
+		if !finite(axis.v.Vector) || lengthSq == 0 {
+			return fmt.Errorf("invalid camera: %s must be a finite, non-zero vector", axis.name)
+		}
+		// hand-edited directions needn't be unit vectors; leave ones that already are alone, so that saved cameras load unchanged
+		if math.Abs(lengthSq-1) > 1e-9 {
+			*axis.v = axis.v.Vector.Unit()
+		}
+	}
+	// HalfHeight, PixelWidth and PixelHeight follow from the size and HalfWidth, so values in the file are ignored
+	*c = camera.WithSize(camera.Width, camera.Height)
+	return nil
+}
+
+type objectJSON struct {
+	Shape, Material json.RawMessage
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	if o.Shape == nil || o.Material == nil {
+		return nil, fmt.Errorf("object must have both a shape and a material")
+	}
+	shape, err := shapeTypes.marshal(o.Shape)
+	if err != nil {
+		return nil, err
+	}
+	material, err := materialTypes.marshal(o.Material)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(objectJSON{shape, material})
+}
+
+func (o *Object) UnmarshalJSON(data []byte) error {
+	var raw objectJSON
+	if err := decodeStrict(data, &raw); err != nil {
+		return err
+	}
+	if raw.Shape == nil {
+		return fmt.Errorf("object is missing a shape")
+	}
+	if raw.Material == nil {
+		return fmt.Errorf("object is missing a material")
+	}
+	shape, err := shapeTypes.unmarshal(raw.Shape)
+	if err != nil {
+		return err
+	}
+	material, err := materialTypes.unmarshal(raw.Material)
+	if err != nil {
+		return err
+	}
+	o.Shape, o.Material = shape, material
+	return nil
+}
+
+type movingJSON struct {
+	Shape     json.RawMessage
+	Axis      Vector
+	Pivot     Vector
+	Keyframes []Keyframe
+}
+
+func (m Moving) MarshalJSON() ([]byte, error) {
+	if m.Shape == nil {
+		return nil, fmt.Errorf("moving shape must have a shape")
+	}
+	shape, err := shapeTypes.marshal(m.Shape)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(movingJSON{shape, m.Axis, m.Pivot, m.Keyframes})
+}
+
+func (m *Moving) UnmarshalJSON(data []byte) error {
+	var raw movingJSON
+	if err := decodeStrict(data, &raw); err != nil {
+		return err
+	}
+	if raw.Shape == nil {
+		return fmt.Errorf("moving shape is missing a shape")
+	}
+	shape, err := shapeTypes.unmarshal(raw.Shape)
+	if err != nil {
+		return err
+	}
+	*m = MakeMoving(shape, raw.Axis, raw.Pivot, raw.Keyframes...)
+	return nil
+}
+
+func (m *Mesh) UnmarshalJSON(data []byte) error {
+	// alias type doesn't have the UnmarshalJSON method, so decoding into it won't recurse
+	type meshJSON Mesh
+	var raw meshJSON
+	if err := decodeStrict(data, &raw); err != nil {
+		return err
+	}
+	for i, face := range raw.Faces {
+		for _, v := range face.Vertices {
+			if v < 0 || v >= len(raw.Vertices) {
+				return fmt.Errorf("face %d refers to vertex %d, but mesh has %d vertices", i, v, len(raw.Vertices))
+			}
+		}
+	}
+	*m = MakeMesh(raw.Vertices, raw.Normals, raw.TexCoords, raw.Faces)
+	return nil
+}
+
+// values of a scene file's ColorSpace field, which says how the colors in it are encoded
+const (
+	// colors are sRGB encoded, as given by most color pickers; this is assumed for files that don't give a color space
+	COLOR_SPACE_SRGB string = "srgb"
+	// colors are the linear values used for shading, as written by EncodeScene
+	COLOR_SPACE_LINEAR string = "linear"
+)
+
+// decode a light written by lightTypes.marshal
+func decodeLight(data []byte) (Light, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil, fmt.Errorf("light must be a JSON object")
+	}
+	var light Light
+	if _, ok := fields[TYPE_FIELD]; ok {
+		var err error
+		if light, err = lightTypes.unmarshal(data); err != nil {
+			return nil, err
+		}
+	} else {
+		// lights saved before there were other kinds of light are point lights
+		var point PointLight
+		if err := decodeStrict(data, &point); err != nil {
+			return nil, fmt.Errorf("invalid light of type %q: %v", "point", err)
+		}
+		light = point
+	}
+	if _, ok := fields["Color"]; !ok {
+		// lights saved before lights had colors are white
+		if colored, ok := light.(ColoredLight); ok {
+			light = colored.MapColors(func(Vector) Vector { return White() })
+		}
+	}
+	return light, nil
+}
+
+type sceneJSON struct {
+	ColorSpace string
+	Camera     Camera
+	Objects    []json.RawMessage
+	Lights     []json.RawMessage
+}
+
+// write the scene as JSON, with linear colors
+func EncodeScene(w io.Writer, s Scene) error {
+	lights := make([]json.RawMessage, len(s.Lights))
+	for i, light := range s.Lights {
+		data, err := lightTypes.marshal(light)
+		if err != nil {
+			return err
+		}
+		lights[i] = data
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		ColorSpace string
+		Camera     Camera
+		Objects    []Object
+		Lights     []json.RawMessage
+	}{COLOR_SPACE_LINEAR, s.Camera, s.Objects, lights})
+}
+
+// read a scene written by EncodeScene
+// colors in files that don't give a ColorSpace are taken to be sRGB, and converted to linear values
+func DecodeScene(r io.Reader) (Scene, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Scene{}, err
+	}
+	var raw sceneJSON
+	if err := decodeStrict(data, &raw); err != nil {
+		return Scene{}, fmt.Errorf("invalid scene: %v", err)
+	}
+	var decodeColor func(Vector) Vector
+	switch raw.ColorSpace {
+	case "", COLOR_SPACE_SRGB:
+		decodeColor = DecodeSRGB
+	case COLOR_SPACE_LINEAR:
+	default:
+		return Scene{}, fmt.Errorf("invalid scene: unknown color space %q; expected %q or %q", raw.ColorSpace, COLOR_SPACE_SRGB, COLOR_SPACE_LINEAR)
+	}
+	s := Scene{Camera: raw.Camera, Lights: make([]Light, len(raw.Lights)), Objects: make([]Object, len(raw.Objects))}
+	for i, data := range raw.Lights {
+		light, err := decodeLight(data)
+		if err != nil {
+			return Scene{}, fmt.Errorf("invalid light %d: %v", i, err)
+		}
+		if colored, ok := light.(ColoredLight); ok && decodeColor != nil {
+			light = colored.MapColors(decodeColor)
+		}
+		s.Lights[i] = light
+	}
+	for i, data := range raw.Objects {
+		if err := s.Objects[i].UnmarshalJSON(data); err != nil {
+			return Scene{}, fmt.Errorf("invalid object %d: %v", i, err)
+		}
+		if m, ok := s.Objects[i].Material.(ColoredMaterial); ok && decodeColor != nil {
+			s.Objects[i].Material = m.MapColors(decodeColor)
+		}
+	}
+	return s, nil
+}
+
+func SaveScene(path string, s Scene) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeScene(f, s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func LoadScene(path string) (Scene, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Scene{}, err
+	}
+	defer f.Close()
+	s, err := DecodeScene(f)
+	if err != nil {
+		return Scene{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
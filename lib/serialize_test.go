@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSceneRoundTrip(t *testing.T) {
+	camera := DefaultCamera(40, 30)
+	camera.Projection = Orthographic{Width: 3}
+	camera.ShutterOpen, camera.ShutterClose = 0, 0.5
+	mesh := MakeMesh(
+		[]Vector{{0, 0, 5}, {1, 0, 5}, {0, 1, 5}, {1, 1, 5}},
+		[]Vector{{0, 0, -1}},
+		[]TexCoord{{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+		[]Face{
+			{Vertices: [3]int{0, 1, 2}, Normals: [3]int{0, 0, 0}, TexCoords: [3]int{0, 1, 2}},
+			{Vertices: [3]int{1, 3, 2}, Normals: [3]int{-1, -1, -1}, TexCoords: [3]int{1, 3, 2}},
+		},
+	)
+	material := ClassicMaterial{Ambient: 0.1, Diffuse: 0.7, Color: Vector{0.2, 0.4, 0.6}, Transparency: 0.5, RefractiveIndex: 1.5}
+	moving := MakeMoving(Sphere{Center: Vector{0, 0, 4}, Radius: 1}, Vector{0, 1, 0}, Vector{0, 0, 4},
+		Keyframe{Time: 0}, Keyframe{Time: 0.5, Offset: Vector{1, 0, 0}, Angle: 0.3})
+	point := MakeLight(Vector{1, 2, 3}, 0.7)
+	point.Color, point.Falloff, point.FalloffRadius = Vector{1, 0.5, 0.25}, INVERSE_SQUARE_FALLOFF, 2
+	s := Scene{
+		Camera: camera,
+		Objects: []Object{
+			{Shape: Sphere{Center: Vector{1, 0, 6}, Radius: 0.5}, Material: material},
+			{Shape: Plane{Point: Vector{0, 1, 0}, Norm: Vector{0, -1, 0}.Unit()}, Material: material},
+			{Shape: Triangle{Vertices: [3]Vector{{0, 0, 3}, {1, 0, 3}, {0, 1, 3}}}, Material: material},
+			{Shape: mesh, Material: material},
+			{Shape: moving, Material: material},
+		},
+		Lights: []Light{
+			point,
+			MakeSpotLight(Vector{0, -3, 3}, Vector{0, 1, 0}, 0.2, 0.4, 1),
+			MakeDirectionalLight(Vector{1, 1, 0}, 0.5),
+			RectLight{Center: Vector{0, -2, 4}, U: Vector{0, 0, 1}, V: Vector{1, 0, 0}, Samples: 4, LightEmission: MakeLightEmission(1)},
+			DiskLight{Center: Vector{0, -2, 4}, Normal: Vector{0, 1, 0}, Radius: 1, Samples: 4, LightEmission: MakeLightEmission(1)},
+			MakeSphereLight(Vector{-1, -1, 4}, 0.5, 1),
+		},
+	}
+	var buf bytes.Buffer
+	if err := EncodeScene(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Camera, decoded.Camera) {
+		t.Errorf("camera changed:\n%+v\n%+v", s.Camera, decoded.Camera)
+	}
+	if !reflect.DeepEqual(s.Objects, decoded.Objects) {
+		t.Errorf("objects changed:\n%+v\n%+v", s.Objects, decoded.Objects)
+	}
+	if !reflect.DeepEqual(s.Lights, decoded.Lights) {
+		t.Errorf("lights changed:\n%+v\n%+v", s.Lights, decoded.Lights)
+	}
+}
+
+func TestDecodeSceneErrors(t *testing.T) {
+	material := `{"Type": "classic", "Diffuse": 1}`
+	object := func(shape string) string {
+		return `{"Objects": [{"Shape": ` + shape + `, "Material": ` + material + `}]}`
+	}
+	cases := []struct {
+		name, scene, want string
+	}{
+		{"not json", `{"Objects": [`, "invalid scene"},
+		{"unknown shape", object(`{"Type": "cube"}`), `unknown shape type "cube"`},
+		{"missing type", object(`{"Center": {"X": 0, "Y": 0, "Z": 0}}`), `missing the "Type" field`},
+		{"misspelled field", object(`{"Type": "sphere", "Raduis": 1}`), `invalid shape of type "sphere"`},
+		{"missing shape", `{"Objects": [{"Material": ` + material + `}]}`, "missing a shape"},
+		{"unknown material", `{"Objects": [{"Shape": {"Type": "sphere"}, "Material": {"Type": "metal"}}]}`, `unknown material type "metal"`},
+		{"bad mesh index", object(`{"Type": "mesh", "Vertices": [{"X": 0, "Y": 0, "Z": 0}], "Faces": [{"Vertices": [0, 0, 3], "Normals": [-1, -1, -1], "TexCoords": [-1, -1, -1]}]}`), "refers to vertex 3"},
+		{"unknown projection", `{"Camera": {"Projection": {"Type": "cylindrical"}}}`, `unknown projection type "cylindrical"`},
+		{"unknown light", `{"Lights": [{"Type": "laser"}]}`, `unknown light type "laser"`},
+		{"unknown falloff", `{"Lights": [{"Falloff": "cubic"}]}`, "invalid light 0"},
+		{"unknown color space", `{"ColorSpace": "cmyk"}`, `unknown color space "cmyk"`},
+		{"tiny image", `{"Camera": {"Width": 1, "Height": 1, "HalfWidth": 1, "LookAt": {"X": 0, "Y": 0, "Z": 1}, "Up": {"X": 0, "Y": 1, "Z": 0}, "Right": {"X": 1, "Y": 0, "Z": 0}}}`, "at least 2x2"},
+		{"zero direction", `{"Camera": {"Width": 4, "Height": 4, "HalfWidth": 1, "Up": {"X": 0, "Y": 1, "Z": 0}, "Right": {"X": 1, "Y": 0, "Z": 0}}}`, "LookAt must be"},
+	}
+	for _, c := range cases {
+		_, err := DecodeScene(strings.NewReader(c.scene))
+		if err == nil {
+			t.Errorf("%s: decoded without error", c.name)
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %q, want it to mention %q", c.name, err, c.want)
+		}
+	}
+}
+
+// fields of a camera that follow from its others are recomputed, so hand edits to its size or directions take effect
+func TestDecodeEditedCamera(t *testing.T) {
+	data, err := json.Marshal(DefaultCamera(40, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	fields["Width"] = 80
+	fields["LookAt"] = map[string]float64{"X": 0, "Y": 0, "Z": 2}
+	if data, err = json.Marshal(fields); err != nil {
+		t.Fatal(err)
+	}
+	var camera Camera
+	if err := json.Unmarshal(data, &camera); err != nil {
+		t.Fatal(err)
+	}
+	if want := DefaultCamera(80, 30); !reflect.DeepEqual(camera, want) {
+		t.Errorf("got camera\n%+v\nwant\n%+v", camera, want)
+	}
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// a pair of cameras for rendering stereoscopic images, one for each eye
+type StereoRig struct {
+	// camera halfway between the eyes; each eye's camera is a copy of it, moved sideways
+	Camera Camera
+	// distance between the eyes, in scene units
+	Interocular float64
+	// distance along the view direction at which the eyes' views converge, so that objects there appear at screen depth
+	// if 0, the views are parallel, and everything appears in front of the screen
+	// convergence shifts each eye's image sideways, rather than rotating the cameras, so that there is no vertical parallax
+	Convergence float64
+}
+
+// shifts a projection's image horizontally by a number of pixels
+type shiftedProjection struct {
+	projection Projection
+	shift      float64
+}
+
+func (p shiftedProjection) Ray(c *Camera, x, y float64, rng *RNG) (Ray, bool) {
+	return p.projection.Ray(c, x+p.shift, y, rng)
+}
+
+// get the cameras for the left and right eyes
+func (r StereoRig) Eyes() (Camera, Camera) {
+	return r.eye(-1), r.eye(1)
+}
+
+// get the camera for the eye on the given side, -1 for left or 1 for right
+func (r StereoRig) eye(side float64) Camera {
+	c := r.Camera
+	offset := side * r.Interocular / 2
+	c.Position = c.Position.Add(c.Right.MulScalar(offset))
+	if r.Convergence > 0 {
+		// a point at the convergence distance straight ahead of the rig lies at offset / convergence from the eye's center,
+		// on an image plane at distance 1
+		projection := c.Projection
+		if projection == nil {
+			projection = DefaultProjection()
+		}
+		c.Projection = shiftedProjection{projection, -offset / r.Convergence / c.PixelWidth}
+	}
+	return c
+}
+
+// render the scene from each eye in turn, as with Scene.RenderContext
+// the scene's own camera is ignored
+func (r StereoRig) RenderContext(ctx context.Context, s Scene, opts RenderOptions) (*image.RGBA, *image.RGBA, error) {
+	left, right := r.Eyes()
+	s.Camera = left
+	leftImage, err := s.RenderContext(ctx, opts)
+	if err != nil {
+		return leftImage, nil, err
+	}
+	s.Camera = right
+	rightImage, err := s.RenderContext(ctx, opts)
+	return leftImage, rightImage, err
+}
+
+// same as RenderContext, but can't be cancelled
+func (r StereoRig) Render(s Scene, opts RenderOptions) (*image.RGBA, *image.RGBA) {
+	left, right, _ := r.RenderContext(context.Background(), s, opts)
+	return left, right
+}
+
+func checkStereoPair(left, right image.Image) error {
+	if left.Bounds().Size() != right.Bounds().Size() {
+		return fmt.Errorf("left and right images must be the same size, got %v and %v", left.Bounds().Size(), right.Bounds().Size())
+	}
+	return nil
+}
+
+// place the left and right images next to each other, left first
+func SideBySide(left, right image.Image) (*image.RGBA, error) {
+	if err := checkStereoPair(left, right); err != nil {
+		return nil, err
+	}
+	size := left.Bounds().Size()
+	img := image.NewRGBA(image.Rect(0, 0, 2*size.X, size.Y))
+	draw.Draw(img, image.Rect(0, 0, size.X, size.Y), left, left.Bounds().Min, draw.Src)
+	draw.Draw(img, image.Rect(size.X, 0, 2*size.X, size.Y), right, right.Bounds().Min, draw.Src)
+	return img, nil
+}
+
+// place the left image above the right one
+func TopBottom(left, right image.Image) (*image.RGBA, error) {
+	if err := checkStereoPair(left, right); err != nil {
+		return nil, err
+	}
+	size := left.Bounds().Size()
+	img := image.NewRGBA(image.Rect(0, 0, size.X, 2*size.Y))
+	draw.Draw(img, image.Rect(0, 0, size.X, size.Y), left, left.Bounds().Min, draw.Src)
+	draw.Draw(img, image.Rect(0, size.Y, size.X, 2*size.Y), right, right.Bounds().Min, draw.Src)
+	return img, nil
+}
+
+// combine the images into a red/cyan anaglyph, to be viewed with a red filter over the left eye
+// the red channel comes from the left image, and the green and blue channels from the right
+func Anaglyph(left, right image.Image) (*image.RGBA, error) {
+	if err := checkStereoPair(left, right); err != nil {
+		return nil, err
+	}
+	size := left.Bounds().Size()
+	img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			l := color.RGBAModel.Convert(left.At(left.Bounds().Min.X+x, left.Bounds().Min.Y+y)).(color.RGBA)
+			r := color.RGBAModel.Convert(right.At(right.Bounds().Min.X+x, right.Bounds().Min.Y+y)).(color.RGBA)
+			img.SetRGBA(x, y, color.RGBA{l.R, r.G, r.B, 255})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// default width and height of the square tiles that images are split into for rendering
+const DEFAULT_TILE_SIZE int = 32
+
+// order in which the tiles of an image are rendered
+type TileOrder int
+
+const (
+	// row by row, from the top left
+	SCANLINE_ORDER TileOrder = iota
+	// outward from the center of the image, in square rings
+	SPIRAL_ORDER
+	// along a Hilbert curve, so that consecutive tiles are usually adjacent
+	// the curve covers the smallest power-of-2 square enclosing the grid, so it jumps where it leaves the grid and comes back
+	HILBERT_ORDER
+)
+
+var tileOrderNames = map[TileOrder]string{
+	SCANLINE_ORDER: "scanline",
+	SPIRAL_ORDER:   "spiral",
+	HILBERT_ORDER:  "hilbert",
+}
+
+func (o TileOrder) String() string {
+	if name, ok := tileOrderNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("TileOrder(%d)", int(o))
+}
+
+// get the tile order with the given name, as returned by TileOrder.String
+func ParseTileOrder(name string) (TileOrder, error) {
+	for order, n := range tileOrderNames {
+		if n == name {
+			return order, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tile order %q; expected scanline, spiral or hilbert", name)
+}
+
+// split an image of the given size into square tiles, listed in the given order
+// tiles at the right and bottom edges are cropped to fit the image
+func tiles(width, height, size int, order TileOrder) []image.Rectangle {
+	cols := (width + size - 1) / size
+	rows := (height + size - 1) / size
+	var cells [][2]int
+	switch order {
+	case SPIRAL_ORDER:
+		cells = spiralCells(cols, rows)
+	case HILBERT_ORDER:
+		cells = hilbertCells(cols, rows)
+	default:
+		cells = scanlineCells(cols, rows)
+	}
+	bounds := image.Rect(0, 0, width, height)
+	result := make([]image.Rectangle, len(cells))
+	for i, c := range cells {
+		result[i] = image.Rect(c[0]*size, c[1]*size, (c[0]+1)*size, (c[1]+1)*size).Intersect(bounds)
+	}
+	return result
+}
+
+func scanlineCells(cols, rows int) [][2]int {
+	cells := make([][2]int, 0, cols*rows)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			cells = append(cells, [2]int{x, y})
+		}
+	}
+	return cells
+}
+
+func spiralCells(cols, rows int) [][2]int {
+	cells := scanlineCells(cols, rows)
+	// position of each cell relative to the center of the grid
+	offset := func(c [2]int) (float64, float64) {
+		return float64(c[0]) - float64(cols-1)/2, float64(c[1]) - float64(rows-1)/2
+	}
+	ring := func(c [2]int) float64 {
+		dx, dy := offset(c)
+		return math.Max(math.Abs(dx), math.Abs(dy))
+	}
+	angle := func(c [2]int) float64 {
+		dx, dy := offset(c)
+		return math.Atan2(dy, dx)
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		ri, rj := ring(cells[i]), ring(cells[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return angle(cells[i]) < angle(cells[j])
+	})
+	return cells
+}
+
+func hilbertCells(cols, rows int) [][2]int {
+	// walk a Hilbert curve over the smallest power-of-two square covering the grid, skipping cells outside it
+	n := 1
+	for n < cols || n < rows {
+		n *= 2
+	}
+	cells := make([][2]int, 0, cols*rows)
+	for d := 0; d < n*n; d++ {
+		x, y := hilbertPoint(n, d)
+		if x < cols && y < rows {
+			cells = append(cells, [2]int{x, y})
+		}
+	}
+	return cells
+}
+
+// get the coordinates of the d-th point along a Hilbert curve filling an n by n grid, where n is a power of 2
+func hilbertPoint(n, d int) (int, int) {
+	x, y := 0, 0
+	for s := 1; s < n; s *= 2 {
+		rx := 1 & (d / 2)
+		ry := 1 & (d ^ rx)
+		if ry == 0 {
+			if rx == 1 {
+				x, y = s-1-x, s-1-y
+			}
+			x, y = y, x
+		}
+		x += s * rx
+		y += s * ry
+		d /= 4
+	}
+	return x, y
+}
@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"image"
+	"math"
+)
+
+// maps linear radiance, which may be any non-negative value, to a displayable color in [0, 1]
+// channels outside [0, 1] after mapping are clamped
+type ToneMapper interface {
+	Map(c Vector) Vector
+}
+
+// the tone mapper used if none is given, which clips bright colors, as if the image were developed without tone mapping
+func DefaultToneMapper() ToneMapper {
+	return ClampToneMapper{}
+}
+
+// clips each channel to [0, 1], so that everything brighter than white becomes white
+type ClampToneMapper struct{}
+
+func (ClampToneMapper) Map(c Vector) Vector {
+	return c.Trim(0, 1)
+}
+
+// compresses each channel with x / (1 + x), so that bright colors approach white without ever clipping
+// this also darkens midtones, which can be made up for with exposure
+type ReinhardToneMapper struct{}
+
+func (ReinhardToneMapper) Map(c Vector) Vector {
+	return Vector{c.X / (1 + c.X), c.Y / (1 + c.Y), c.Z / (1 + c.Z)}
+}
+
+// Reinhard tone mapping, adjusted so that WhitePoint and anything brighter map to white
+// if WhitePoint is 0, this is the same as ReinhardToneMapper
+type ExtendedReinhardToneMapper struct {
+	WhitePoint float64
+}
+
+func (m ExtendedReinhardToneMapper) Map(c Vector) Vector {
+	invWhiteSq := 0.
+	if m.WhitePoint > 0 {
+		invWhiteSq = 1 / (m.WhitePoint * m.WhitePoint)
+	}
+	f := func(x float64) float64 {
+		return x * (1 + x*invWhiteSq) / (1 + x)
+	}
+	return Vector{f(c.X), f(c.Y), f(c.Z)}
+}
+
+// filmic curve approximating the ACES reference rendering transform, with a toe in the shadows and a soft shoulder in the highlights
+// uses Krzysztof Narkowicz's fit
+type ACESToneMapper struct{}
+
+func (ACESToneMapper) Map(c Vector) Vector {
+	f := func(x float64) float64 {
+		return x * (2.51*x + 0.03) / (x*(2.43*x+0.59) + 0.14)
+	}
+	return Vector{f(c.X), f(c.Y), f(c.Z)}
+}
+
+// apply exposure, in stops, and tone mapping to a linear color, giving a color in [0, 1]
+func toneMap(c Vector, mapper ToneMapper, exposure float64) Vector {
+	if exposure != 0 {
+		c = c.MulScalar(math.Exp2(exposure))
+	}
+	// radiance should never be negative, but tone mappers needn't handle it if it is
+	return mapper.Map(c.Trim(0, math.Inf(1))).Trim(0, 1)
+}
+
+// linear radiance for each pixel of an image, before tone mapping
+type Framebuffer struct {
+	Rect image.Rectangle
+	// radiance of each pixel, indexed by (y - Rect.Min.Y) * Rect.Dx() + (x - Rect.Min.X)
+	Radiance []Vector
+	// whether any samples reached each pixel, indexed the same way as Radiance
+	Rendered []bool
+}
+
+func NewFramebuffer(r image.Rectangle) *Framebuffer {
+	n := r.Dx() * r.Dy()
+	return &Framebuffer{Rect: r, Radiance: make([]Vector, n), Rendered: make([]bool, n)}
+}
+
+func (f *Framebuffer) index(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Rect.Dx() + (x - f.Rect.Min.X)
+}
+
+// get the radiance of a pixel, and whether it was rendered
+func (f *Framebuffer) At(x, y int) (Vector, bool) {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		return Zero(), false
+	}
+	i := f.index(x, y)
+	return f.Radiance[i], f.Rendered[i]
+}
+
+// convert to an 8-bit image, as configured by d
+// pixels that weren't rendered are left transparent
+func (f *Framebuffer) Image(d Display) *image.RGBA {
+	d = d.withDefaults()
+	img := image.NewRGBA(f.Rect)
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		for x := f.Rect.Min.X; x < f.Rect.Max.X; x++ {
+			if i := f.index(x, y); f.Rendered[i] {
+				img.SetRGBA(x, y, d.rgba(f.Radiance[i], x, y))
+			}
+		}
+	}
+	return img
+}
+
+// convert to a 16-bit image, as configured by d, which avoids the banding that 8 bits can show in smooth gradients
+// pixels that weren't rendered are left transparent
+func (f *Framebuffer) Image16(d Display) *image.RGBA64 {
+	d = d.withDefaults()
+	img := image.NewRGBA64(f.Rect)
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		for x := f.Rect.Min.X; x < f.Rect.Max.X; x++ {
+			if i := f.index(x, y); f.Rendered[i] {
+				img.SetRGBA64(x, y, d.rgba64(f.Radiance[i], x, y))
+			}
+		}
+	}
+	return img
+}
@@ -96,6 +96,11 @@ func (v Vector) Rotate(w Vector, theta float64) Vector {
 	return parallel.Add(orthoRot)
 }
 
+// apply the exponential function to each coordinate
+func (v Vector) Exp() Vector {
+	return Vector{math.Exp(v.X), math.Exp(v.Y), math.Exp(v.Z)}
+}
+
 func (v Vector) Trim(min, max float64) Vector {
 	return Vector{
 		math.Min(math.Max(v.X, min), max),
@@ -104,16 +109,22 @@ func (v Vector) Trim(min, max float64) Vector {
 	}
 }
 
-func (v Vector) ToColor() (*color.RGBA, error) {
+// convert a linear color to an 8-bit sRGB color
+func (v Vector) ToColor() (color.RGBA, error) {
 	if v.X < 0 || v.Y < 0 || v.Z < 0 || v.X > 1 || v.Y > 1 || v.Z > 1 {
-		return nil, fmt.Errorf("Vector %v cannot be converted to color; all coordinates must be in [0, 1]", v)
+		return color.RGBA{}, fmt.Errorf("Vector %v cannot be converted to color; all coordinates must be in [0, 1]", v)
 	}
-	return &color.RGBA{
+	return EncodeSRGB(v).toRGBA(), nil
+}
+
+// convert to an opaque color, assuming all coordinates are in [0, 1]
+func (v Vector) toRGBA() color.RGBA {
+	return color.RGBA{
 		uint8(v.X * 255),
 		uint8(v.Y * 255),
 		uint8(v.Z * 255),
 		255,
-	}, nil
+	}
 }
 
 func (v Vector) String() string {